@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"songLibrary/internal/export"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+)
+
+const exportUsage = `usage:
+  export [-format csv|ndjson|m3u|xspf] [-lyrics] [-o file]
+      write the library to a file or standard output; the format is taken from the
+      file extension unless given, csv by default`
+
+// runExport implements the "export" subcommand and returns the process exit code.
+func runExport(log *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	format := flags.String("format", "", "")
+	lyrics := flags.Bool("lyrics", false, "")
+	output := flags.String("o", "", "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+
+	if *format == "" && *output != "" {
+		*format = export.FormatOf(*output)
+	}
+	if *format == "" {
+		*format = export.DefaultFormat
+	}
+	if !export.ValidFormat(*format) {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+
+	db := storage.Connection(log)
+	defer db.Close()
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "export:", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	writer, err := export.NewWriter(out, *format, *lyrics)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 2
+	}
+
+	count := 0
+	err = postgres.NewStorage(db).ExportLibrary(context.Background(), *lyrics, func(song postgres.Songs) error {
+		count++
+		return writer.Write(song)
+	}, log)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+
+	if *output != "" {
+		fmt.Printf("exported %d songs to %s\n", count, *output)
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"songLibrary/internal/catalog"
+	"songLibrary/internal/config"
+	"songLibrary/internal/importer"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"syscall"
+)
+
+const importUsage = `usage:
+  import [-dry-run] [-format csv|json|ndjson] [-workers n] [-json] <file|->
+      add the group and song pairs of a file to the library, looking each up in the catalog;
+      the format is taken from the file extension unless given, - reads standard input`
+
+// runImport implements the "import" subcommand and returns the process exit code: 1 when
+// any row failed.
+func runImport(cfg *config.Config, log *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+
+	dryRun := flags.Bool("dry-run", false, "")
+	format := flags.String("format", "", "")
+	workers := flags.Int("workers", cfg.Import.Workers, "")
+	asJSON := flags.Bool("json", false, "")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = importer.FormatOf(path)
+	}
+	if !importer.ValidFormat(*format) {
+		fmt.Fprintln(os.Stderr, "import: cannot tell the format of", path+", use -format csv, json or ndjson")
+		return 2
+	}
+
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "import:", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	db := storage.Connection(log)
+	defer db.Close()
+
+	importCfg := cfg.Import
+	importCfg.Workers = *workers
+
+	im := importer.New(postgres.NewStorage(db), catalog.NewClient(cfg.Catalog), importCfg, log)
+
+	rows, err := im.Parse(in, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report := im.Run(ctx, rows, *dryRun)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		for _, row := range report.Rows {
+			fmt.Printf("%-6d %-15s %s - %s", row.Line, row.Status, row.Group, row.Song)
+			if row.Error != "" {
+				fmt.Printf(" (%s)", row.Error)
+			}
+			fmt.Println()
+		}
+
+		prefix := ""
+		if report.DryRun {
+			prefix = "dry run: "
+		}
+		fmt.Printf("%s%d rows: %d added, %d duplicate, %d not in catalog, %d failed\n",
+			prefix, report.Total, report.Added, report.Duplicate, report.NotInCatalog, report.Failed)
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/migrations"
+	"songLibrary/internal/storage/postgres"
+	"strconv"
+)
+
+const keysUsage = `usage:
+  keys create <name> <reader|editor|admin>   create a key and print it once
+  keys list                                  list keys without their secrets
+  keys delete <id>                           revoke a key`
+
+// runKeys implements the "keys" subcommand and returns the process exit code.
+func runKeys(log *slog.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	db := storage.Connection(log)
+	defer db.Close()
+
+	// The keys table comes from a migration, so a fresh database has to be migrated first.
+	if err := migrations.NewMigrator(db).Up(log); err != nil {
+		fmt.Fprintln(os.Stderr, "keys:", err)
+		return 1
+	}
+
+	store := postgres.NewStorage(db)
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "create" && len(args) == 3 && auth.ValidRole(args[2]):
+		secret, prefix, hash, err := auth.GenerateKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keys create:", err)
+			return 1
+		}
+
+		key, err := store.CreateKey(ctx, args[1], args[2], prefix, hash, log)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keys create:", err)
+			return 1
+		}
+
+		fmt.Printf("created key %d (%s, %s):\n%s\n", key.ID, key.Name, key.Role, secret)
+	case args[0] == "list" && len(args) == 1:
+		keys, err := store.ListKeys(ctx, log)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keys list:", err)
+			return 1
+		}
+
+		for _, key := range keys {
+			fmt.Printf("%-5d %-12s %-7s %-30s %s\n", key.ID, key.Prefix, key.Role, key.Name, key.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+	case args[0] == "delete" && len(args) == 2:
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, keysUsage)
+			return 2
+		}
+
+		if err = store.DeleteKey(ctx, id, log); err != nil {
+			fmt.Fprintln(os.Stderr, "keys delete:", err)
+			return 1
+		}
+	default:
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	return 0
+}
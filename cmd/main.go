@@ -1,16 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"songLibrary/internal/api"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/catalog"
 	"songLibrary/internal/config"
+	"songLibrary/internal/health"
+	"songLibrary/internal/importer"
+	"songLibrary/internal/metrics"
+	"songLibrary/internal/plays"
 	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/memory"
+	"songLibrary/internal/storage/migrations"
 	"songLibrary/internal/storage/postgres"
 	"songLibrary/internal/swager"
+	"songLibrary/internal/tracing"
+	"songLibrary/internal/trash"
+	"syscall"
+	"time"
 )
 
 const (
@@ -24,36 +41,222 @@ func main() {
 
 	log := setupLogger(cfg.Env)
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(log, os.Args[2:]))
+		case "keys":
+			os.Exit(runKeys(log, os.Args[2:]))
+		case "import":
+			os.Exit(runImport(cfg, log, os.Args[2:]))
+		case "export":
+			os.Exit(runExport(log, os.Args[2:]))
+		}
+	}
+
 	log.Info("starting api", slog.String("key", cfg.Env))
 	log.Debug("debug message enable")
 
-	db := storage.Connection(log)
+	flushTraces, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Error("Error setting up tracing", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		log.Info("exporting traces", slog.String("exporter", cfg.Tracing.Exporter))
+	}
+
+	storageDB := setupStorage(cfg.Storage, log)
+
+	if pg, ok := storageDB.(*postgres.Storage); ok {
+		metrics.WatchDB(pg.DB())
+	}
+	metrics.WatchLibrary(storageDB, log)
 	router := chi.NewRouter()
 
-	storageDB := postgres.NewStorage(db)
-	log.Info("db connection successful")
+	workers := newBackground()
+
+	catalogClient := catalog.NewClient(cfg.Catalog)
+
+	checker := setupHealth(cfg.Health, storageDB, catalogClient)
+
+	recorder := plays.NewRecorder(storageDB, cfg.Plays, log)
+	workers.Go(recorder.Run)
 
-	storageDB.CreateTable(log)
-	storageDB.MigrateLibrary(log)
+	workers.Go(trash.NewPurger(storageDB, cfg.Trash, log).Run)
+
+	authenticator := auth.New(storageDB, !cfg.Auth.Disabled, cfg.Auth.TrustedProxy, log)
+	if cfg.Auth.BootstrapKey != "" {
+		if err := authenticator.Bootstrap(cfg.Auth.BootstrapKey); err != nil {
+			log.Error("Error storing bootstrap api key", "error", err)
+		}
+	}
+	if cfg.Auth.Disabled {
+		log.Warn("api key authentication is disabled")
+	}
+	if cfg.Auth.TrustedProxy {
+		log.Warn("users are taken from the " + auth.HeaderUserID + " header of the trusted proxy")
+	}
 
-	swager.InitRoutes(router, log, storageDB)
+	router.Use(request.RequestID)
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(authenticator.Middleware)
+
+	reader := router.With(authenticator.Require(auth.RoleReader))
+	editor := router.With(authenticator.Require(auth.RoleEditor))
+	admin := router.With(authenticator.Require(auth.RoleAdmin))
+
+	// GET responses carry an ETag, so clients can revalidate them with If-None-Match. The
+	// export is streamed and stays out of it.
+	cached := reader.With(api.ConditionalGet)
+
+	swager.InitRoutes(router, log, storageDB, catalogClient, authenticator)
 
 	router.Mount("/swagger", httpSwagger.WrapHandler)
+	router.Handle("/metrics", metrics.Handler())
+	router.Get("/healthz", api.LivenessHandler())
+	router.Get("/readyz", api.ReadinessHandler(log, checker))
 
-	router.Post("/songLibrary/AddSong", api.AddSongHandler(log, storageDB))
-	router.Post("/songLibrary/ChangeInfo", api.ChangeInfoSongHandler(log, storageDB))
-	router.Delete("/songLibrary/DeleteSong", api.DeleteSongHandler(log, storageDB))
-	router.Get("/songLibrary/TextSong", api.TextSongHandler(log, storageDB))
-	router.Get("/songLibrary/Library", api.LibraryHandler(log, storageDB))
-	router.Get("/songLibrary/info", api.InfoHandler(log, storageDB))
+	editor.Post("/songLibrary/AddSong", api.AddSongHandler(log, storageDB, catalogClient))
+	editor.Post("/songLibrary/Import", api.ImportHandler(log, importer.New(storageDB, catalogClient, cfg.Import, log)))
+	editor.Post("/songLibrary/ChangeInfo", api.ChangeInfoSongHandler(log, storageDB))
+	admin.Delete("/songLibrary/DeleteSong", api.DeleteSongHandler(log, storageDB))
+	cached.Get("/songLibrary/Song", api.SongHandler(log, storageDB))
+	editor.Patch("/songLibrary/Song", api.PatchSongHandler(log, storageDB))
+	cached.Get("/songLibrary/TextSong", api.TextSongHandler(log, storageDB))
+	cached.Get("/songLibrary/Library", api.LibraryHandler(log, storageDB))
+	reader.Get("/songLibrary/Export", api.ExportHandler(log, storageDB))
+	cached.Get("/songLibrary/info", api.InfoHandler(log, storageDB))
+	cached.Get("/songLibrary/search", api.SearchHandler(log, storageDB))
 
-	router.Get("/Library", api.LibraryMainHandler(log, storageDB))
+	cached.Get("/songLibrary/Revisions", api.RevisionsHandler(log, storageDB))
+	cached.Get("/songLibrary/Revisions/diff", api.RevisionDiffHandler(log, storageDB))
+	editor.Post("/songLibrary/Revisions/rollback", api.RollbackInfoHandler(log, storageDB, storageDB))
 
-	err := http.ListenAndServe(cfg.Address, router)
-	if err != nil {
-		log.Error("Error starting server", err)
+	editor.With(api.ConditionalGet).Get("/songLibrary/Trash", api.TrashHandler(log, storageDB))
+	editor.Post("/songLibrary/Trash/restore", api.RestoreSongHandler(log, storageDB))
+	admin.Delete("/songLibrary/Trash", api.PurgeSongHandler(log, storageDB))
+
+	editor.Post("/songLibrary/Playlist", api.CreatePlaylistHandler(log, storageDB))
+	cached.Get("/songLibrary/Playlists", api.PlaylistsHandler(log, storageDB))
+	cached.Get("/songLibrary/Playlist", api.PlaylistHandler(log, storageDB))
+	editor.Put("/songLibrary/Playlist", api.RenamePlaylistHandler(log, storageDB))
+	admin.Delete("/songLibrary/Playlist", api.DeletePlaylistHandler(log, storageDB))
+	editor.Post("/songLibrary/Playlist/songs", api.AddToPlaylistHandler(log, storageDB))
+	editor.Delete("/songLibrary/Playlist/songs", api.RemoveFromPlaylistHandler(log, storageDB))
+	editor.Put("/songLibrary/Playlist/order", api.MovePlaylistSongHandler(log, storageDB))
+
+	cached.Get("/songLibrary/Artists", api.ArtistsHandler(log, storageDB))
+	cached.Get("/songLibrary/Artist", api.ArtistHandler(log, storageDB))
+	cached.Get("/songLibrary/Artist/songs", api.ArtistSongsHandler(log, storageDB, storageDB))
+	editor.Put("/songLibrary/Artist", api.RenameArtistHandler(log, storageDB))
+
+	editor.Post("/songLibrary/Album", api.CreateAlbumHandler(log, storageDB))
+	cached.Get("/songLibrary/Albums", api.AlbumsHandler(log, storageDB))
+	cached.Get("/songLibrary/Album", api.AlbumHandler(log, storageDB))
+	editor.Put("/songLibrary/Album/tracks", api.SetAlbumTracksHandler(log, storageDB))
+	admin.Delete("/songLibrary/Album", api.DeleteAlbumHandler(log, storageDB))
+
+	cached.Get("/songLibrary/Genres", api.GenresHandler(log, storageDB))
+	editor.Post("/songLibrary/Genre", api.CreateGenreHandler(log, storageDB))
+	editor.Post("/songLibrary/Song/genres", api.AddSongGenreHandler(log, storageDB))
+	editor.Delete("/songLibrary/Song/genres", api.RemoveSongGenreHandler(log, storageDB))
+	editor.Post("/songLibrary/Song/tags", api.AddSongTagsHandler(log, storageDB))
+	editor.Delete("/songLibrary/Song/tags", api.RemoveSongTagHandler(log, storageDB))
+	cached.Get("/songLibrary/Tags", api.TagsHandler(log, storageDB))
+
+	cached.Get("/songLibrary/Favorites", api.FavoritesHandler(log, storageDB))
+	reader.Put("/songLibrary/Favorites", api.AddFavoriteHandler(log, storageDB))
+	reader.Delete("/songLibrary/Favorites", api.RemoveFavoriteHandler(log, storageDB))
+	cached.Get("/songLibrary/Ratings", api.RatingsHandler(log, storageDB))
+	reader.Put("/songLibrary/Rating", api.RateSongHandler(log, storageDB))
+	reader.Delete("/songLibrary/Rating", api.RemoveRatingHandler(log, storageDB))
+	cached.Get("/songLibrary/TopRated", api.TopRatedHandler(log, storageDB))
+
+	reader.Post("/songLibrary/Play", api.RecordPlayHandler(log, recorder))
+	cached.Get("/songLibrary/Plays/recent", api.RecentPlaysHandler(log, storageDB))
+	cached.Get("/songLibrary/Stats/songs", api.MostPlayedSongsHandler(log, storageDB))
+	cached.Get("/songLibrary/Stats/artists", api.MostPlayedArtistsHandler(log, storageDB))
+	cached.Get("/songLibrary/Stats/daily", api.DailyPlaysHandler(log, storageDB))
+
+	cached.Get("/Library", api.LibraryMainHandler(log, storageDB))
+
+	admin.Get("/admin/keys", api.KeysHandler(log, storageDB))
+	admin.Post("/admin/keys", api.CreateKeyHandler(log, storageDB))
+	admin.Delete("/admin/keys", api.DeleteKeyHandler(log, storageDB))
+
+	srv := &http.Server{
+		Addr:              cfg.Address,
+		Handler:           router,
+		ReadTimeout:       cfg.HttpServer.ReadTimeout,
+		ReadHeaderTimeout: cfg.HttpServer.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HttpServer.WriteTimeout,
+		IdleTimeout:       cfg.HttpServer.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(log.Handler(), slog.LevelError),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("server started", slog.String("address", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Error("Error starting server", "error", err)
+		}
+	case <-ctx.Done():
+		log.Info("shutdown signal received, draining connections", slog.Duration("grace_period", cfg.ShutdownTimeout))
 	}
+	stop()
 
+	checker.Drain()
+	if cfg.ShutdownDelay > 0 {
+		log.Info("reporting not ready before shutdown", slog.Duration("delay", cfg.ShutdownDelay))
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	shutdown(log, srv, workers, storageDB, flushTraces, cfg.ShutdownTimeout)
+}
+
+// shutdown stops the server from accepting connections and waits for in-flight requests,
+// then stops background work, closes the storage and flushes the traces, all within gracePeriod.
+func shutdown(log *slog.Logger, srv *http.Server, workers *background, store storage.Store, flushTraces func(context.Context) error, gracePeriod time.Duration) {
+	deadline := time.Now().Add(gracePeriod)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("Error draining connections, closing them", "error", err)
+		srv.Close()
+	} else {
+		log.Info("http server stopped")
+	}
+
+	if workers.Stop(time.Until(deadline)) {
+		log.Info("background work stopped")
+	} else {
+		log.Warn("background work did not stop within the grace period")
+	}
+
+	if err := store.Close(); err != nil {
+		log.Error("Error closing storage", "error", err)
+	} else {
+		log.Info("storage closed")
+	}
+
+	if err := flushTraces(ctx); err != nil {
+		log.Error("Error flushing traces", "error", err)
+	}
 }
 
 func setupLogger(env string) *slog.Logger {
@@ -71,3 +274,59 @@ func setupLogger(env string) *slog.Logger {
 
 	return log
 }
+
+// setupHealth checks the database, its Library table and migrations when the storage is
+// PostgreSQL, and the catalog when the config asks for it.
+func setupHealth(cfg config.Health, store storage.Store, catalogClient *catalog.Client) *health.Checker {
+	var checks []health.Check
+
+	if pg, ok := store.(*postgres.Storage); ok {
+		migrator := migrations.NewMigrator(pg.DB())
+
+		checks = append(checks,
+			health.Check{Name: "database", Run: pg.Ping},
+			health.Check{Name: "library", Run: pg.CheckLibrary},
+			health.Check{Name: "migrations", Run: func(ctx context.Context) error {
+				pending, err := migrator.Pending(ctx)
+				if err == nil && len(pending) > 0 {
+					mg := pending[0]
+					err = fmt.Errorf("%d migrations pending, first %s %04d_%s", len(pending), mg.Kind, mg.Version, mg.Name)
+				}
+				return err
+			}},
+		)
+	}
+
+	if cfg.CheckCatalog {
+		checks = append(checks, health.Check{Name: "catalog", Run: catalogClient.Ping})
+	}
+
+	return health.New(cfg.Timeout, checks...)
+}
+
+func setupStorage(storageType string, log *slog.Logger) storage.Store {
+
+	switch storageType {
+	case storage.TypeMemory:
+		storageMem := memory.NewStorage()
+		storageMem.Seed(log)
+		log.Info("using in-memory storage")
+
+		return storageMem
+	case storage.TypePostgres:
+	default:
+		log.Warn("unknown storage type, falling back to postgres", slog.String("storage", storageType))
+	}
+
+	db := storage.Connection(log)
+
+	if err := migrations.NewMigrator(db).Up(log); err != nil {
+		log.Error("Error to migrate database", "error", err)
+		os.Exit(1)
+	}
+
+	storageDB := postgres.NewStorage(db)
+	log.Info("db connection successful")
+
+	return storageDB
+}
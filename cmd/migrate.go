@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/migrations"
+	"strconv"
+)
+
+const migrateUsage = `usage:
+  migrate up [schema|seed]   apply pending migrations, each seed right after the schema it needs;
+                             with a kind, stop before the first pending migration of the other
+  migrate down [n]           roll back the last n applied migrations (1 by default)
+  migrate status             list migrations and when they were applied`
+
+// runMigrate implements the "migrate" subcommand and returns the process exit code.
+func runMigrate(log *slog.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db := storage.Connection(log)
+	defer db.Close()
+
+	migrator := migrations.NewMigrator(db)
+
+	switch args[0] {
+	case "up":
+		var kinds []migrations.Kind
+		for _, arg := range args[1:] {
+			kind := migrations.Kind(arg)
+			if kind != migrations.KindSchema && kind != migrations.KindSeed {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			kinds = append(kinds, kind)
+		}
+
+		if err := migrator.Up(log, kinds...); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate up:", err)
+			return 1
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+			steps = n
+		}
+
+		if err := migrator.Down(log, steps); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate down:", err)
+			return 1
+		}
+	case "status":
+		statuses, err := migrator.Status(log)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate status:", err)
+			return 1
+		}
+
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-6s %04d %-30s %s\n", st.Kind, st.Version, st.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
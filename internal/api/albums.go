@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strings"
+)
+
+// AlbumTracksRequest lists library song IDs in track order.
+type AlbumTracksRequest struct {
+	Tracks []int `json:"tracks"`
+}
+
+// CreateAlbumHandler godoc
+// @Summary Create an album
+// @Description Create an album of an artist, found by name or alias, with library songs as its tracks in order. The type is LP, EP or single, LP by default.
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Param album body postgres.NewAlbum true "Album"
+// @Success 201 {object} postgres.AlbumDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "A track is not in the library"
+// @Failure 409 {object} request.ErrorResponse "The artist already has an album with this title"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Album [post]
+func CreateAlbumHandler(log *slog.Logger, storage storage.AlbumStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.CreateAlbumHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var album postgres.NewAlbum
+		if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		album.Title = strings.TrimSpace(album.Title)
+		if album.Type == "" {
+			album.Type = postgres.AlbumLP
+		}
+
+		if album.Title == "" || postgres.CleanArtistName(album.Artist) == "" || !postgres.ValidAlbumType(album.Type) {
+			request.Write(w, request.BadRequest("title and artist are required and type must be LP, EP or single"))
+			return
+		}
+		if !validTracks(w, album.Tracks) {
+			return
+		}
+
+		details, err := storage.CreateAlbum(r.Context(), album, log)
+		if err != nil {
+			albumError(w, log, op, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(details)
+		log.Info("album successfully created", "id", details.ID)
+	}
+}
+
+// AlbumsHandler godoc
+// @Summary List albums
+// @Description List albums newest first, optionally of one artist or one type
+// @Tags albums
+// @Produce json
+// @Param artist query int false "Artist ID"
+// @Param type query string false "Album type: LP, EP or single"
+// @Param limit query int false "Page size, 50 by default, at most 500"
+// @Param offset query int false "Number of albums to skip"
+// @Success 200 {object} postgres.AlbumPage
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Albums [get]
+func AlbumsHandler(log *slog.Logger, storage storage.AlbumStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AlbumsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parseAlbumQuery(r)
+		if err != nil {
+			log.Error("Error parsing album query", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		albums, err := storage.ListAlbums(r.Context(), q, log)
+		if err != nil {
+			albumError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(albums)
+		log.Info("albums successfully received")
+	}
+}
+
+// AlbumHandler godoc
+// @Summary Get an album
+// @Description Get an album with its tracks in order
+// @Tags albums
+// @Produce json
+// @Param id query int true "Album ID"
+// @Success 200 {object} postgres.AlbumDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Album [get]
+func AlbumHandler(log *slog.Logger, storage storage.AlbumStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AlbumHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		details, err := storage.GetAlbum(r.Context(), id, log)
+		if err != nil {
+			albumError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(details)
+		log.Info("album successfully received", "id", id)
+	}
+}
+
+// SetAlbumTracksHandler godoc
+// @Summary Set the tracks of an album
+// @Description Replace the track listing of an album with library songs in track order
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Param id query int true "Album ID"
+// @Param tracks body AlbumTracksRequest true "Song IDs in track order"
+// @Success 200 {object} postgres.AlbumDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Album not found or a track is not in the library"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Album/tracks [put]
+func SetAlbumTracksHandler(log *slog.Logger, storage storage.AlbumStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.SetAlbumTracksHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var req AlbumTracksRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+		if !validTracks(w, req.Tracks) {
+			return
+		}
+
+		details, err := storage.SetAlbumTracks(r.Context(), id, req.Tracks, log)
+		if err != nil {
+			albumError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(details)
+		log.Info("album tracks successfully set", "id", id, "tracks", len(req.Tracks))
+	}
+}
+
+// DeleteAlbumHandler godoc
+// @Summary Delete an album
+// @Description Delete an album. Its songs stay in the library.
+// @Tags albums
+// @Produce json
+// @Param id query int true "Album ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Album [delete]
+func DeleteAlbumHandler(log *slog.Logger, storage storage.AlbumStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.DeleteAlbumHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.DeleteAlbum(r.Context(), id, log); err != nil {
+			albumError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("album successfully deleted", "id", id)
+	}
+}
+
+// validTracks answers 400 unless every track is a positive song ID that occurs once.
+func validTracks(w http.ResponseWriter, tracks []int) bool {
+	seen := make(map[int]bool, len(tracks))
+	for _, songID := range tracks {
+		if songID <= 0 || seen[songID] {
+			request.Write(w, request.Invalid("tracks", "tracks must be distinct song ids"))
+			return false
+		}
+		seen[songID] = true
+	}
+	return true
+}
+
+func albumError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrAlbumNotFound):
+		problem = request.Error(http.StatusNotFound, "Error album not found").WithCode(request.CodeAlbumNotFound)
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrAlbumExists):
+		problem = request.Error(http.StatusConflict, "Error the artist already has an album with this title").WithCode(request.CodeDuplicateAlbum)
+	default:
+		log.Error("Error in album storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
@@ -1,16 +1,17 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"github.com/lib/pq"
+	"errors"
 	"log/slog"
 	"net/http"
-	url2 "net/url"
 	"songLibrary/internal/api/request"
-	"songLibrary/internal/api/response"
+	"songLibrary/internal/catalog"
+	"songLibrary/internal/lyrics"
+	"songLibrary/internal/storage"
 	"songLibrary/internal/storage/postgres"
-	"strconv"
+	"songLibrary/internal/tracing"
 )
 
 // AddSongHandler godoc
@@ -20,13 +21,18 @@ import (
 // @Accept json
 // @Produce json
 // @Param song body postgres.Song true "Song Data"
-// @Success 200 {object} request.OkResponse
+// @Success 200 {object} postgres.Songs
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Song is not in the catalog"
+// @Failure 409 {object} request.ErrorResponse "Song is already in the library or in the trash"
 // @Failure 500 {object} request.ErrorResponse
+// @Failure 502 {object} request.ErrorResponse "Catalog returned a malformed response"
+// @Failure 503 {object} request.ErrorResponse "Catalog is unavailable"
 // @Router /song/add [post]
-func AddSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func AddSongHandler(log *slog.Logger, storage storage.SongStore, catalogClient *catalog.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.AddSongHandler()"
+		log := tracing.Logger(r.Context(), log)
 
 		var song postgres.Song
 		w.Header().Set("Content-Type", "application/json")
@@ -34,47 +40,47 @@ func AddSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFun
 		err := json.NewDecoder(r.Body).Decode(&song)
 		if err != nil {
 			log.Error("Error decoding request body", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error decoding request body"))
+			request.Write(w, request.BadRequest("Error decoding request body"))
 			return
 		}
 
-		url := fmt.Sprintf("http://0.0.0.0:8081/info?group=%s&song=%s", url2.QueryEscape(song.Group), url2.QueryEscape(song.Name))
-		infoSong, err := response.GetInfoSong(log, url)
+		infoSong, err := catalogClient.GetInfo(r.Context(), song.Group, song.Name, log)
 		if err != nil {
 			log.Error("Error getting info song in library", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error decoding request body"))
+
+			problem := request.InternalServer("Error getting info song in library")
+			switch {
+			case errors.Is(err, catalog.ErrNotFound):
+				problem = request.Error(http.StatusNotFound, "Error library don't have this song").WithCode(request.CodeSongNotInCatalog)
+			case errors.Is(err, catalog.ErrUnavailable):
+				problem = request.Error(http.StatusServiceUnavailable, "Error song library is unavailable").WithCode(request.CodeCatalogUnavailable)
+			case errors.Is(err, catalog.ErrMalformed):
+				problem = request.Error(http.StatusBadGateway, "Error song library returned a malformed response").WithCode(request.CodeCatalogBadResponse)
+			}
+
+			request.Write(w, problem)
 			return
 		}
 
-		if infoSong.ReleaseDate == nil {
-			log.Error("Error library don't have this song", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error library don't have this song"))
+		added, err := storage.AddSong(r.Context(), song, infoSong, log)
+		if errors.Is(err, postgres.ErrSongExists) {
+			request.Write(w, request.Error(http.StatusConflict, "Error song is already in the library").WithCode(request.CodeDuplicateSong))
 			return
 		}
-
-		id, err := storage.AddSong(song, log)
-		if err != nil {
-			log.Error("Error adding song", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			pgErr, _ := err.(*pq.Error)
-			json.NewEncoder(w).Encode(request.InternalServer(pgErr.Message))
+		if errors.Is(err, postgres.ErrSongInTrash) {
+			request.Write(w, request.Error(http.StatusConflict, "Error song is in the trash, restore it instead").WithCode(request.CodeSongInTrash))
 			return
 		}
-
-		err = response.ChangeInfoSong(log, infoSong, id)
 		if err != nil {
-			log.Error("Error changing song info", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			pgErr, _ := err.(*pq.Error)
-			json.NewEncoder(w).Encode(request.InternalServer(pgErr.Message))
+			log.Error("Error adding song", "error", err, "operation", op)
+			request.Write(w, storageProblem(err))
 			return
 		}
 
+		// A new song is at its first version.
+		w.Header().Set("ETag", songETag(1))
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(request.Ok())
+		json.NewEncoder(w).Encode(added)
 		log.Info("song successfully added")
 		return
 	}
@@ -82,46 +88,55 @@ func AddSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFun
 
 // ChangeInfoSongHandler godoc
 // @Summary Update song information
-// @Description Update the information for an existing song by its ID
+// @Description Update the information for an existing song by its ID. The change is recorded as a revision of the song. With If-Match the song must still have that ETag.
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param id query int true "Song ID"
+// @Param If-Match header string false "ETag of the song the change is made against"
 // @Param song body postgres.InfoSong true "Updated Song Info"
 // @Success 200 {object} request.OkResponse
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 412 {object} request.ErrorResponse "Song changed since the ETag in If-Match"
 // @Failure 500 {object} request.ErrorResponse
 // @Router /song/change [put]
-func ChangeInfoSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func ChangeInfoSongHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.AddInfoSongHandler()"
+		log := tracing.Logger(r.Context(), log)
 
-		id, err := strconv.Atoi(r.URL.Query().Get("id"))
-		if err != nil {
-			log.Error("no id or transmitted incorrectly", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
+		id, ok := queryID(w, r, log, op)
+		if !ok {
 			return
 		}
 
 		var infoSong postgres.InfoSong
-		err = json.NewDecoder(r.Body).Decode(&infoSong)
+		err := json.NewDecoder(r.Body).Decode(&infoSong)
 		if err != nil {
 			log.Error("Error decoding request body", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error decoding request body"))
+			request.Write(w, request.BadRequest("Error decoding request body"))
 			return
 		}
 
-		status, err := storage.ChangeInfo(id, infoSong, log)
+		editor, ok := requestEditor(w, r)
+		if !ok {
+			return
+		}
+
+		ifVersion, err := ifMatchVersion(r, storage, id, log)
+		if err == nil {
+			var version int
+			if version, err = storage.ChangeInfo(r.Context(), id, infoSong, editor, ifVersion, log); err == nil {
+				w.Header().Set("ETag", songETag(version))
+			}
+		}
 		if err != nil {
-			log.Error("Error changing song info", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			pgErr, _ := err.(*pq.Error)
-			json.NewEncoder(w).Encode(request.InternalServer(pgErr.Message))
+			songError(w, log, op, err)
 			return
 		}
 
-		w.WriteHeader(status)
+		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(request.Ok())
 		log.Info("song successfully changed")
 		return
@@ -130,39 +145,45 @@ func ChangeInfoSongHandler(log *slog.Logger, storage *postgres.Storage) http.Han
 
 // DeleteSongHandler godoc
 // @Summary Delete a song
-// @Description Delete a song by its ID
+// @Description Move a song to the trash by its ID. It is hidden from the library until restored, and purged for good once the trash retention has passed. With If-Match the song must still have that ETag.
 // @Tags songs
 // @Produce json
 // @Param id query int true "Song ID"
+// @Param If-Match header string false "ETag of the song the delete is made against"
 // @Success 200 {object} request.OkResponse
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 412 {object} request.ErrorResponse "Song changed since the ETag in If-Match"
 // @Failure 500 {object} request.ErrorResponse
 // @Router /song/delete [delete]
-func DeleteSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func DeleteSongHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.DeleteSongHandler()"
+		log := tracing.Logger(r.Context(), log)
 
-		id, err := strconv.Atoi(r.URL.Query().Get("id"))
-		if err != nil {
-			log.Error("no id or transmitted incorrectly", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
+		id, ok := queryID(w, r, log, op)
+		if !ok {
 			return
 		}
 
-		result, err := storage.DeleteSong(id, log)
+		ifVersion, err := ifMatchVersion(r, storage, id, log)
+
+		var result sql.Result
+		if err == nil {
+			result, err = storage.DeleteSong(r.Context(), id, ifVersion, log)
+		}
 		if err != nil {
-			log.Error("Error deleting song", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			pgErr, _ := err.(*pq.Error)
-			json.NewEncoder(w).Encode(request.InternalServer(pgErr.Message))
+			songError(w, log, op, err)
 			return
 		}
 
 		rowsAffected, err := result.RowsAffected()
-		if rowsAffected == 0 || err != nil {
-			log.Error("Error deleting song, song with this id not found", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error deleting song, song id not found"))
+		if err != nil {
+			songError(w, log, op, err)
+			return
+		}
+		if rowsAffected == 0 {
+			songError(w, log, op, postgres.ErrSongNotFound)
 			return
 		}
 
@@ -173,38 +194,97 @@ func DeleteSongHandler(log *slog.Logger, storage *postgres.Storage) http.Handler
 	}
 }
 
+// SongHandler godoc
+// @Summary Get a song
+// @Description Get a song of the library with its info. The ETag of the response is the one If-Match of changes to the song expects; with If-None-Match naming it the answer is 304 Not Modified.
+// @Tags songs
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param If-None-Match header string false "ETag the client already has"
+// @Success 200 {object} postgres.Songs
+// @Success 304 "Song has not changed"
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song [get]
+func SongHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.SongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		song, version, err := storage.GetSong(r.Context(), id, log)
+		if err != nil {
+			songError(w, log, op, err)
+			return
+		}
+
+		w.Header().Set("ETag", songETag(version))
+		json.NewEncoder(w).Encode(song)
+		log.Info("song successfully received", "id", id)
+	}
+}
+
 // TextSongHandler godoc
 // @Summary Get song lyrics
-// @Description Retrieve the lyrics of a song by its ID
+// @Description Retrieve the lyrics of a song by its ID, as one string or split into numbered verses or lines
 // @Tags songs
 // @Produce json
 // @Param id query int true "Song ID"
+// @Param format query string false "text (default), verses or lines"
+// @Param page query int false "Page of verses or lines, starting from 1"
+// @Param per_page query int false "Verses or lines per page, all of them by default"
 // @Success 200 {object} string "Song Lyrics"
+// @Success 200 {object} lyrics.VersePage "format=verses"
+// @Success 200 {object} lyrics.LinePage "format=lines"
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
 // @Failure 500 {object} request.ErrorResponse
 // @Router /song/text [get]
-func TextSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func TextSongHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.TextSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
 
-		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		format, page, perPage, err := parseLyricsQuery(r)
 		if err != nil {
-			log.Error("no id or transmitted incorrectly", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
+			log.Error("Error parsing lyrics query", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
 			return
 		}
 
-		text, err := storage.GetText(id, log)
+		text, err := storage.GetText(r.Context(), id, log)
 		if err != nil {
-			log.Error("Error getting song text", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			pgErr, _ := err.(*pq.Error)
-			json.NewEncoder(w).Encode(request.InternalServer(pgErr.Message))
+			songError(w, log, op, err)
+			return
+		}
+
+		var body any = text
+		switch format {
+		case lyricsVerses:
+			body, err = lyrics.Verses(text, page, perPage)
+		case lyricsLines:
+			body, err = lyrics.NumberedLines(text, page, perPage)
+		}
+		if err != nil {
+			log.Error("Error paginating song text", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(text)
+		json.NewEncoder(w).Encode(body)
 		log.Info("lyrics of the song successfully received")
 		return
 	}
@@ -215,18 +295,40 @@ func TextSongHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFu
 // @Description Retrieve a list of all songs available in the library
 // @Tags library
 // @Produce json
-// @Success 200 {array} postgres.Song
+// @Param group query string false "Filter by artist name or alias (case-insensitive)"
+// @Param song query string false "Filter by song name substring"
+// @Param tag query string false "Only songs with this tag"
+// @Param genre query string false "Only songs in this genre or one of its subgenres"
+// @Param released_from query string false "Released on or after, YYYY-MM-DD"
+// @Param released_to query string false "Released on or before, YYYY-MM-DD"
+// @Param has_link query bool false "Only songs with (true) or without (false) a link"
+// @Param album_dates query bool false "Take the release date from the earliest album of a song that has none"
+// @Param sort query string false "Sort field: id, group, song, releaseDate or link"
+// @Param order query string false "Sort direction: asc or desc"
+// @Param limit query int false "Page size, 50 by default, at most 500"
+// @Param offset query int false "Number of songs to skip"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Success 200 {object} postgres.LibraryPage
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
 // @Router /library [get]
-func LibraryHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func LibraryHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.LibraryHandler()"
+		log := tracing.Logger(r.Context(), log)
 
-		library, err := storage.GetLibrary(log)
+		q, err := parseLibraryQuery(r)
+		if err != nil {
+			log.Error("Error parsing library query", "error", err, "operation", op)
+			w.Header().Set("Content-Type", "application/json")
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		library, err := storage.GetLibrary(r.Context(), q, log)
 		if err != nil {
 			log.Error("Error getting library", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error getting library"))
+			request.Write(w, storageProblem(err))
 			return
 		}
 
@@ -244,20 +346,20 @@ func LibraryHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFun
 // @Param group query string true "Music Group"
 // @Param song query string true "Song Name"
 // @Success 200 {object} postgres.InfoSong
+// @Failure 404 {object} request.ErrorResponse
 // @Failure 500 {object} request.ErrorResponse
 // @Router /info [get]
-func InfoHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func InfoHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.InfoHandler()"
+		log := tracing.Logger(r.Context(), log)
 
 		group := r.URL.Query().Get("group")
 		song := r.URL.Query().Get("song")
 
-		info, err := storage.GetInfo(group, song, log)
+		info, err := storage.GetInfo(r.Context(), group, song, log)
 		if err != nil {
-			log.Error("Error getting info", "error", err, "operation", op)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(request.InternalServer("Error getting info"))
+			songError(w, log, op, err)
 			return
 		}
 
@@ -273,18 +375,39 @@ func InfoHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
 // @Description Retrieve the main library information
 // @Tags library
 // @Produce json
-// @Success 200 {array} postgres.Song
+// @Param group query string false "Filter by artist name or alias (case-insensitive)"
+// @Param song query string false "Filter by song name substring"
+// @Param tag query string false "Only songs with this tag"
+// @Param genre query string false "Only songs in this genre or one of its subgenres"
+// @Param released_from query string false "Released on or after, YYYY-MM-DD"
+// @Param released_to query string false "Released on or before, YYYY-MM-DD"
+// @Param has_link query bool false "Only songs with (true) or without (false) a link"
+// @Param sort query string false "Sort field: id, group, song, releaseDate or link"
+// @Param order query string false "Sort direction: asc or desc"
+// @Param limit query int false "Page size, 50 by default, at most 500"
+// @Param offset query int false "Number of songs to skip"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Success 200 {object} postgres.LibraryPage
 // @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
 // @Router /library/main [get]
-func LibraryMainHandler(log *slog.Logger, storage *postgres.Storage) http.HandlerFunc {
+func LibraryMainHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.api.LibraryHandlerDB()"
+		log := tracing.Logger(r.Context(), log)
 
-		library, err := storage.GetLibraryMain(log)
+		q, err := parseLibraryQuery(r)
+		if err != nil {
+			log.Error("Error parsing library query", "error", err, "operation", op)
+			w.Header().Set("Content-Type", "application/json")
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		library, err := storage.GetLibraryMain(r.Context(), q, log)
 		if err != nil {
 			log.Error("Error getting library", "error", err, "operation", op)
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(request.BadRequest("Error getting library"))
+			request.Write(w, storageProblem(err))
 			return
 		}
 
@@ -293,3 +416,73 @@ func LibraryMainHandler(log *slog.Logger, storage *postgres.Storage) http.Handle
 		log.Info("library successfully received")
 	}
 }
+
+// SearchHandler godoc
+// @Summary Search songs by lyrics, group or title
+// @Description Ranked full-text search over the user library and the global Library catalog
+// @Tags library
+// @Produce json
+// @Param q query string true "Words or phrase to search for"
+// @Param lang query string false "Stemming configuration: english (default) or russian"
+// @Param scope query string false "all (default), library or catalog"
+// @Param limit query int false "Number of results, 20 by default, at most 100"
+// @Success 200 {array} postgres.SearchResult
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /search [get]
+func SearchHandler(log *slog.Logger, storage storage.SearchStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.SearchHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, err := parseIntParam(r.URL.Query().Get("limit"), "limit")
+		if err != nil {
+			log.Error("Error parsing search query", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		q := postgres.SearchQuery{
+			Text:  r.URL.Query().Get("q"),
+			Lang:  r.URL.Query().Get("lang"),
+			Scope: r.URL.Query().Get("scope"),
+			Limit: limit,
+		}
+		if err = q.Normalize(); err != nil {
+			log.Error("Error parsing search query", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		results, err := storage.Search(r.Context(), q, log)
+		if err != nil {
+			log.Error("Error searching songs", "error", err, "operation", op)
+			request.Write(w, storageProblem(err))
+			return
+		}
+
+		json.NewEncoder(w).Encode(results)
+		log.Info("search successfully completed")
+	}
+}
+
+func songError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrVersionMismatch):
+		problem = request.Error(http.StatusPreconditionFailed, "Error song changed since the ETag in If-Match").WithCode(request.CodeVersionMismatch)
+	case errors.Is(err, postgres.ErrSongExists):
+		problem = request.Error(http.StatusConflict, "Error another song with that group and title is already in the library").WithCode(request.CodeDuplicateSong)
+	case errors.Is(err, postgres.ErrSongInTrash):
+		problem = request.Error(http.StatusConflict, "Error another song with that group and title is in the trash").WithCode(request.CodeSongInTrash)
+	default:
+		log.Error("Error in song storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
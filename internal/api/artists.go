@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strings"
+)
+
+// RenameArtistRequest sets the canonical name of an artist. An empty sort name is derived
+// from the name.
+type RenameArtistRequest struct {
+	Name     string `json:"name"`
+	SortName string `json:"sortName"`
+}
+
+// ArtistsHandler godoc
+// @Summary List artists
+// @Description List all artists by sort name, with their aliases and the number of songs in the library
+// @Tags artists
+// @Produce json
+// @Success 200 {array} postgres.Artist
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Artists [get]
+func ArtistsHandler(log *slog.Logger, storage storage.ArtistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ArtistsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		artists, err := storage.ListArtists(r.Context(), log)
+		if err != nil {
+			artistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(artists)
+		log.Info("artists successfully received")
+	}
+}
+
+// ArtistHandler godoc
+// @Summary Get an artist
+// @Tags artists
+// @Produce json
+// @Param id query int true "Artist ID"
+// @Success 200 {object} postgres.Artist
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Artist [get]
+func ArtistHandler(log *slog.Logger, storage storage.ArtistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ArtistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		artist, err := storage.GetArtist(r.Context(), id, log)
+		if err != nil {
+			artistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(artist)
+		log.Info("artist successfully received", "id", id)
+	}
+}
+
+// ArtistSongsHandler godoc
+// @Summary List the songs of an artist
+// @Description List the library songs of an artist with the filters, sorting and paging of the library listing
+// @Tags artists
+// @Produce json
+// @Param id query int true "Artist ID"
+// @Param song query string false "Filter by song name substring"
+// @Param tag query string false "Only songs with this tag"
+// @Param genre query string false "Only songs in this genre or one of its subgenres"
+// @Param released_from query string false "Released on or after, YYYY-MM-DD"
+// @Param released_to query string false "Released on or before, YYYY-MM-DD"
+// @Param has_link query bool false "Only songs with (true) or without (false) a link"
+// @Param album_dates query bool false "Take the release date from the earliest album of a song that has none"
+// @Param sort query string false "Sort field: id, group, song, releaseDate or link"
+// @Param order query string false "Sort direction: asc or desc"
+// @Param limit query int false "Page size, 50 by default, at most 500"
+// @Param offset query int false "Number of songs to skip"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Success 200 {object} postgres.LibraryPage
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Artist/songs [get]
+func ArtistSongsHandler(log *slog.Logger, artists storage.ArtistStore, songs storage.SongStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ArtistSongsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		q, err := parseLibraryQuery(r)
+		if err != nil {
+			log.Error("Error parsing library query", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		if _, err = artists.GetArtist(r.Context(), id, log); err != nil {
+			artistError(w, log, op, err)
+			return
+		}
+
+		q.ArtistID = id
+		library, err := songs.GetLibrary(r.Context(), q, log)
+		if err != nil {
+			artistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(library)
+		log.Info("artist songs successfully received", "id", id)
+	}
+}
+
+// RenameArtistHandler godoc
+// @Summary Rename an artist
+// @Description Change the canonical name of an artist for all of its songs. The old name stays as an alias.
+// @Tags artists
+// @Accept json
+// @Produce json
+// @Param id query int true "Artist ID"
+// @Param artist body RenameArtistRequest true "New name and optional sort name"
+// @Success 200 {object} postgres.Artist
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 409 {object} request.ErrorResponse "Another artist has this name or alias"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Artist [put]
+func RenameArtistHandler(log *slog.Logger, storage storage.ArtistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RenameArtistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var req RenameArtistRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		if postgres.CleanArtistName(req.Name) == "" {
+			request.Write(w, request.Invalid("name", "name is required"))
+			return
+		}
+
+		artist, err := storage.RenameArtist(r.Context(), id, req.Name, strings.TrimSpace(req.SortName), log)
+		if err != nil {
+			artistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(artist)
+		log.Info("artist successfully renamed", "id", id, "name", artist.Name)
+	}
+}
+
+func artistError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrArtistNotFound):
+		problem = request.Error(http.StatusNotFound, "Error artist not found").WithCode(request.CodeArtistNotFound)
+	case errors.Is(err, postgres.ErrArtistExists):
+		problem = request.Error(http.StatusConflict, "Error another artist already has this name or alias").WithCode(request.CodeDuplicateArtist)
+	default:
+		log.Error("Error in artist storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/storage"
+	"strconv"
+	"strings"
+)
+
+// songETag is the entity tag of a song at a version. Anything that changes the song, its
+// info or the name of its artist changes the version.
+func songETag(version int) string {
+	return `"v` + strconv.Itoa(version) + `"`
+}
+
+// parseSongETag returns the version a strong song entity tag names.
+func parseSongETag(tag string) (int, bool) {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) || len(tag) < 4 {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag[2 : len(tag)-1])
+	return version, err == nil && version > 0
+}
+
+// ifMatchVersion returns the version of the song a change must be made against: 0 when the
+// request has no If-Match or If-Match is *, and -1, which no song is at, when none of the
+// tags it lists is the tag of the song as it is now.
+func ifMatchVersion(r *http.Request, store storage.SongStore, id int, log *slog.Logger) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		if version, ok := parseSongETag(tag); ok {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return -1, nil
+	case 1:
+		return versions[0], nil
+	}
+
+	// With several tags the change is made against the current version if it is among them;
+	// should the song change meanwhile, the storage still refuses the change.
+	_, current, err := store.GetSong(r.Context(), id, log)
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == current {
+			return current, nil
+		}
+	}
+	return -1, nil
+}
+
+// noneMatch tells whether an If-None-Match header lists a tag matching etag, comparing
+// tags weakly as RFC 9110 asks.
+func noneMatch(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ConditionalGet gives every successful GET response an entity tag and answers 304 Not
+// Modified when If-None-Match already has it. Handlers of a versioned resource set the
+// tag themselves; any other response is tagged by a hash of its body, which is why the
+// response is buffered and this must not wrap streamed responses such as the export.
+func ConditionalGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(buf.body.Bytes())
+			etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+		}
+
+		if noneMatch(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// bufferedResponse holds back the status and the body of a response.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status, b.wroteHeader = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
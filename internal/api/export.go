@@ -0,0 +1,100 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/export"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strconv"
+	"time"
+)
+
+// ExportHandler godoc
+// @Summary Export the library
+// @Description Stream every song of the library as CSV, NDJSON, extended M3U (songs with a link only) or XSPF, chosen by format or else by the Accept header, CSV by default
+// @Tags songs
+// @Produce text/csv,application/x-ndjson,audio/x-mpegurl,application/xspf+xml
+// @Param format query string false "csv, ndjson, m3u or xspf"
+// @Param lyrics query bool false "Include lyrics (not in m3u)"
+// @Success 200 {file} file
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 406 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Export [get]
+func ExportHandler(log *slog.Logger, storage storage.ExportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ExportHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		format := r.URL.Query().Get("format")
+		switch {
+		case format == "":
+			var ok bool
+			if format, ok = export.Negotiate(r.Header.Get("Accept")); !ok {
+				request.Write(w, request.Error(http.StatusNotAcceptable, "Error accepted types are text/csv, application/x-ndjson, audio/x-mpegurl and application/xspf+xml"))
+				return
+			}
+		case !export.ValidFormat(format):
+			request.Write(w, request.Invalid("format", "format must be csv, ndjson, m3u or xspf"))
+			return
+		}
+
+		var lyrics bool
+		if v := r.URL.Query().Get("lyrics"); v != "" {
+			var err error
+			if lyrics, err = strconv.ParseBool(v); err != nil {
+				request.Write(w, request.Invalid("lyrics", "lyrics must be true or false"))
+				return
+			}
+		}
+
+		writer, err := export.NewWriter(w, format, lyrics)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		// Large libraries take longer to stream than the server write timeout allows.
+		if err = http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("Error lifting write deadline for export", "error", err, "operation", op)
+		}
+
+		count := 0
+		err = storage.ExportLibrary(r.Context(), lyrics, func(song postgres.Songs) error {
+			if count == 0 {
+				setExportHeaders(w, format)
+			}
+			count++
+			return writer.Write(song)
+		}, log)
+		if err != nil {
+			log.Error("Error exporting library", "error", err, "songs", count, "operation", op)
+			if count == 0 {
+				request.Write(w, storageProblem(err))
+				return
+			}
+			// The export is under way, so its status is sent; cut the connection to
+			// show the client it is incomplete.
+			panic(http.ErrAbortHandler)
+		}
+
+		if count == 0 {
+			setExportHeaders(w, format)
+		}
+		if err = writer.Close(); err != nil {
+			log.Error("Error finishing export", "error", err, "operation", op)
+			panic(http.ErrAbortHandler)
+		}
+
+		log.Info("library successfully exported", "format", format, "songs", count)
+	}
+}
+
+func setExportHeaders(w http.ResponseWriter, format string) {
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="library.`+format+`"`)
+	w.Header().Set("Vary", "Accept")
+}
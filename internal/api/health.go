@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/health"
+	"songLibrary/internal/tracing"
+)
+
+// LivenessHandler godoc
+// @Summary Liveness probe
+// @Description Answers as long as the process serves requests. Dependencies are not checked, so a restart cannot fix what this reports.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /healthz [get]
+func LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		json.NewEncoder(w).Encode(health.Report{Status: health.StatusAlive})
+	}
+}
+
+// ReadinessHandler godoc
+// @Summary Readiness probe
+// @Description Checks the database (a ping, a query of the Library table and that every migration is applied) and, when configured, the catalog, and reports the status and latency of each. Not ready while the service shuts down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report "A check failed or the service is shutting down"
+// @Router /readyz [get]
+func ReadinessHandler(log *slog.Logger, checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ReadinessHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		report := checker.Check(r.Context())
+		for name, check := range report.Checks {
+			if check.Status != health.StatusUp {
+				log.Warn("Readiness check failed", "check", name, "error", check.Error, "operation", op)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if !report.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/importer"
+	"songLibrary/internal/tracing"
+	"strconv"
+	"time"
+)
+
+// maxImportSize bounds the body of an import request.
+const maxImportSize = 10 << 20
+
+// ImportHandler godoc
+// @Summary Import songs in bulk
+// @Description Add the group and song pairs of a CSV (with a group and a song column), JSON array or NDJSON file to the library, looking each up in the catalog. Reports every row as added, duplicate, not_in_catalog or failed. With dry_run nothing is written.
+// @Tags songs
+// @Accept text/csv,application/json,application/x-ndjson
+// @Produce json
+// @Param format query string false "csv, json or ndjson; taken from Content-Type when not given"
+// @Param dry_run query bool false "Report without adding songs"
+// @Success 200 {object} importer.Report
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 413 {object} request.ErrorResponse
+// @Router /songLibrary/Import [post]
+func ImportHandler(log *slog.Logger, im *importer.Importer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.ImportHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = importer.FormatOf(r.Header.Get("Content-Type"))
+		}
+		if !importer.ValidFormat(format) {
+			request.Write(w, request.Invalid("format", "format must be csv, json or ndjson"))
+			return
+		}
+
+		var dryRun bool
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				request.Write(w, request.Invalid("dry_run", "dry_run must be true or false"))
+				return
+			}
+		}
+
+		rows, err := im.Parse(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				request.Write(w, request.Error(http.StatusRequestEntityTooLarge, "Error import file is larger than 10 MB"))
+				return
+			}
+			log.Error("Error parsing import file", "error", err, "operation", op)
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		// An import may run longer than the server write timeout; the importer has its own.
+		if err = http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("Error lifting write deadline for import", "error", err, "operation", op)
+		}
+
+		report := im.Run(r.Context(), rows, dryRun)
+
+		json.NewEncoder(w).Encode(report)
+		log.Info("songs imported", "dry_run", dryRun, "total", report.Total, "added", report.Added,
+			"duplicate", report.Duplicate, "not_in_catalog", report.NotInCatalog, "failed", report.Failed)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strconv"
+	"strings"
+)
+
+type CreateKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// CreatedKey is returned once, when a key is created; the key itself is not stored.
+type CreatedKey struct {
+	postgres.APIKey
+	Key string `json:"key"`
+}
+
+// KeysHandler godoc
+// @Summary List API keys
+// @Description List all API keys without their secrets
+// @Tags admin
+// @Produce json
+// @Success 200 {array} postgres.APIKey
+// @Failure 401 {object} request.ErrorResponse
+// @Failure 403 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /admin/keys [get]
+func KeysHandler(log *slog.Logger, storage storage.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.KeysHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		keys, err := storage.ListKeys(r.Context(), log)
+		if err != nil {
+			log.Error("Error getting api keys", "error", err, "operation", op)
+			request.Write(w, request.InternalServer("Error getting api keys"))
+			return
+		}
+
+		json.NewEncoder(w).Encode(keys)
+		log.Info("api keys successfully received")
+	}
+}
+
+// CreateKeyHandler godoc
+// @Summary Create an API key
+// @Description Create a key with the reader, editor or admin role. The key is only returned in this response.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body CreateKeyRequest true "Key name and role"
+// @Success 201 {object} CreatedKey
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse
+// @Failure 403 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /admin/keys [post]
+func CreateKeyHandler(log *slog.Logger, storage storage.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.CreateKeyHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var req CreateKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || !auth.ValidRole(req.Role) {
+			request.Write(w, request.BadRequest("name is required and role must be reader, editor or admin"))
+			return
+		}
+
+		secret, prefix, hash, err := auth.GenerateKey()
+		if err != nil {
+			log.Error("Error generating api key", "error", err, "operation", op)
+			request.Write(w, request.InternalServer("Error generating api key"))
+			return
+		}
+
+		key, err := storage.CreateKey(r.Context(), req.Name, req.Role, prefix, hash, log)
+		if err != nil {
+			log.Error("Error creating api key", "error", err, "operation", op)
+			request.Write(w, storageProblem(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CreatedKey{APIKey: key, Key: secret})
+		log.Info("api key successfully created", "key", key.Prefix, "role", key.Role)
+	}
+}
+
+// DeleteKeyHandler godoc
+// @Summary Delete an API key
+// @Description Revoke an API key by its ID
+// @Tags admin
+// @Produce json
+// @Param id query int true "Key ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse
+// @Failure 403 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /admin/keys [delete]
+func DeleteKeyHandler(log *slog.Logger, storage storage.KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.DeleteKeyHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			log.Error("no id or transmitted incorrectly", "error", err, "operation", op)
+			request.Write(w, request.Invalid("id", "no id or transmitted incorrectly"))
+			return
+		}
+
+		err = storage.DeleteKey(r.Context(), id, log)
+		if errors.Is(err, postgres.ErrKeyNotFound) {
+			request.Write(w, request.Error(http.StatusNotFound, "Error api key not found").WithCode(request.CodeKeyNotFound))
+			return
+		}
+		if err != nil {
+			log.Error("Error deleting api key", "error", err, "operation", op)
+			request.Write(w, storageProblem(err))
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("api key successfully deleted", "id", id)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strings"
+	"time"
+)
+
+// mergePatchType is the media type of a JSON Merge Patch (RFC 7386).
+const mergePatchType = "application/merge-patch+json"
+
+// maxPatchSize bounds the body of a patch; lyrics make up most of it.
+const maxPatchSize = 1 << 20
+
+// PatchSongHandler godoc
+// @Summary Patch a song
+// @Description Change a song with a JSON Merge Patch (RFC 7386) of the song as GET /songLibrary/Song returns it: fields left out stay as they are, null clears a field and a value replaces it. A cleared text or link becomes an empty string, a cleared release date null. song.group and song.song rename the song and cannot be cleared; info_song: null clears the whole info. A change to the info is recorded as a revision. With If-Match the song must still have that ETag.
+// @Tags songs
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param If-Match header string false "ETag of the song the change is made against"
+// @Param patch body object true "Merge patch of the song"
+// @Success 200 {object} postgres.Songs
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 409 {object} request.ErrorResponse "Another song has that group and title"
+// @Failure 412 {object} request.ErrorResponse "Song changed since the ETag in If-Match"
+// @Failure 415 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song [patch]
+func PatchSongHandler(log *slog.Logger, storage storage.SongStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.PatchSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+				w.Header().Set("Accept-Patch", mergePatchType)
+				request.Write(w, request.Error(http.StatusUnsupportedMediaType, "Error patch must be "+mergePatchType))
+				return
+			}
+		}
+
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, maxPatchSize)); err != nil {
+			log.Error("Error reading request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error reading request body"))
+			return
+		}
+
+		patch, err := parseSongPatch(body.Bytes(), id)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		editor, ok := requestEditor(w, r)
+		if !ok {
+			return
+		}
+
+		ifVersion, err := ifMatchVersion(r, storage, id, log)
+		if err != nil {
+			songError(w, log, op, err)
+			return
+		}
+
+		song, version, err := storage.PatchSong(r.Context(), id, patch, editor, ifVersion, log)
+		if err != nil {
+			songError(w, log, op, err)
+			return
+		}
+
+		w.Header().Set("ETag", songETag(version))
+		json.NewEncoder(w).Encode(song)
+		log.Info("song successfully patched", "id", id, "version", version)
+	}
+}
+
+// parseSongPatch reads a merge patch of the song with the id. Members the song does not
+// have are refused rather than ignored, so a misspelled field is not silently lost.
+func parseSongPatch(data []byte, id int) (postgres.SongPatch, error) {
+	var patch postgres.SongPatch
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil || doc == nil {
+		return patch, errors.New("patch must be a JSON object")
+	}
+
+	for key, value := range doc {
+		switch key {
+		case "id":
+			var patchID int
+			if err := json.Unmarshal(value, &patchID); err != nil || patchID != id {
+				return patch, invalid("id", "id cannot be changed")
+			}
+
+		case "song":
+			var song map[string]json.RawMessage
+			if err := json.Unmarshal(value, &song); err != nil || song == nil {
+				return patch, invalid("song", "song must be an object, it cannot be cleared")
+			}
+			for key, value := range song {
+				var name *string
+				switch key {
+				case "group":
+					name = new(string)
+					patch.Group = name
+				case "song":
+					name = new(string)
+					patch.Name = name
+				default:
+					return patch, invalid("song."+key, "unknown field song."+key)
+				}
+				if err := json.Unmarshal(value, name); err != nil || strings.TrimSpace(*name) == "" {
+					return patch, invalid("song."+key, "song."+key+" must be a non-empty string, it cannot be cleared")
+				}
+				*name = strings.TrimSpace(*name)
+			}
+
+		case "info_song":
+			if isNull(value) {
+				patch.Fields = []string{postgres.FieldReleaseDate, postgres.FieldText, postgres.FieldLink}
+				continue
+			}
+			var info map[string]json.RawMessage
+			if err := json.Unmarshal(value, &info); err != nil {
+				return patch, invalid("info_song", "info_song must be an object or null")
+			}
+			for key, value := range info {
+				if err := parseInfoField(&patch, key, value); err != nil {
+					return patch, err
+				}
+			}
+
+		default:
+			return patch, invalid(key, "unknown field "+key)
+		}
+	}
+
+	return patch, nil
+}
+
+// parseInfoField sets one field of the info from a merge patch; null clears it, which for
+// the text and the link means an empty string.
+func parseInfoField(patch *postgres.SongPatch, key string, value json.RawMessage) error {
+	switch key {
+	case postgres.FieldReleaseDate:
+		if !isNull(value) {
+			var date string
+			if err := json.Unmarshal(value, &date); err != nil {
+				return invalid("info_song.releaseDate", "info_song.releaseDate must be a date or null")
+			}
+			releaseDate, err := parseReleaseDate(date)
+			if err != nil {
+				return invalid("info_song.releaseDate", "info_song.releaseDate must be a date like 2006-01-02 or null")
+			}
+			patch.Info.ReleaseDate = &releaseDate
+		}
+	case postgres.FieldText, postgres.FieldLink:
+		field := &patch.Info.Text
+		if key == postgres.FieldLink {
+			field = &patch.Info.Link
+		}
+		if !isNull(value) {
+			if err := json.Unmarshal(value, field); err != nil {
+				return invalid("info_song."+key, "info_song."+key+" must be a string or null")
+			}
+		}
+	default:
+		return invalid("info_song."+key, "unknown field info_song."+key)
+	}
+
+	patch.Fields = append(patch.Fields, key)
+	return nil
+}
+
+// parseReleaseDate accepts a date alone or the timestamp the API returns dates as.
+func parseReleaseDate(date string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, date); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, date)
+}
+
+func isNull(value json.RawMessage) bool {
+	return string(bytes.TrimSpace(value)) == "null"
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strconv"
+	"strings"
+)
+
+type PlaylistRequest struct {
+	Name string `json:"name"`
+}
+
+// PlaylistSongRequest names a library song and the 1-based position it should take.
+// Position 0 means the end of the playlist.
+type PlaylistSongRequest struct {
+	SongID   int `json:"songId"`
+	Position int `json:"position"`
+}
+
+// CreatePlaylistHandler godoc
+// @Summary Create a playlist
+// @Description Create an empty playlist with the given name
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param playlist body PlaylistRequest true "Playlist name"
+// @Success 201 {object} postgres.Playlist
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist [post]
+func CreatePlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.CreatePlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		name, ok := decodePlaylistName(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		playlist, err := storage.CreatePlaylist(r.Context(), name, log)
+		if err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(playlist)
+		log.Info("playlist successfully created", "id", playlist.ID)
+	}
+}
+
+// PlaylistsHandler godoc
+// @Summary List playlists
+// @Description List all playlists with the number of songs in each
+// @Tags playlists
+// @Produce json
+// @Success 200 {array} postgres.Playlist
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlists [get]
+func PlaylistsHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.PlaylistsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		playlists, err := storage.ListPlaylists(r.Context(), log)
+		if err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(playlists)
+		log.Info("playlists successfully received")
+	}
+}
+
+// PlaylistHandler godoc
+// @Summary Get a playlist
+// @Description Get a playlist with its songs in playlist order
+// @Tags playlists
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Success 200 {object} postgres.PlaylistDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist [get]
+func PlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.PlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		playlist, err := storage.GetPlaylist(r.Context(), id, log)
+		if err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(playlist)
+		log.Info("playlist successfully received", "id", id)
+	}
+}
+
+// RenamePlaylistHandler godoc
+// @Summary Rename a playlist
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Param playlist body PlaylistRequest true "New playlist name"
+// @Success 200 {object} postgres.Playlist
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist [put]
+func RenamePlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RenamePlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		name, ok := decodePlaylistName(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		playlist, err := storage.RenamePlaylist(r.Context(), id, name, log)
+		if err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(playlist)
+		log.Info("playlist successfully renamed", "id", id)
+	}
+}
+
+// DeletePlaylistHandler godoc
+// @Summary Delete a playlist
+// @Description Delete a playlist. The songs stay in the library.
+// @Tags playlists
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist [delete]
+func DeletePlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.DeletePlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.DeletePlaylist(r.Context(), id, log); err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("playlist successfully deleted", "id", id)
+	}
+}
+
+// AddToPlaylistHandler godoc
+// @Summary Add a song to a playlist
+// @Description Insert a library song at the given 1-based position, or at the end when position is 0 or omitted
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Param song body PlaylistSongRequest true "Song ID and position"
+// @Success 200 {object} postgres.PlaylistDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Playlist or song not found"
+// @Failure 409 {object} request.ErrorResponse "Song is already in the playlist"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist/songs [post]
+func AddToPlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AddToPlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		req, ok := decodePlaylistSong(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.AddToPlaylist(r.Context(), id, req.SongID, req.Position, log); err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		writePlaylist(w, r, log, storage, id, op)
+		log.Info("song successfully added to playlist", "id", id, "id_song", req.SongID)
+	}
+}
+
+// RemoveFromPlaylistHandler godoc
+// @Summary Remove a song from a playlist
+// @Description Remove a song from a playlist; the songs after it move up
+// @Tags playlists
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Param song query int true "Song ID"
+// @Success 200 {object} postgres.PlaylistDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Playlist not found or song not in it"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist/songs [delete]
+func RemoveFromPlaylistHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RemoveFromPlaylistHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		songID, err := strconv.Atoi(r.URL.Query().Get("song"))
+		if err != nil {
+			log.Error("no song or transmitted incorrectly", "error", err, "operation", op)
+			request.Write(w, request.Invalid("song", "no song or transmitted incorrectly"))
+			return
+		}
+
+		if err = storage.RemoveFromPlaylist(r.Context(), id, songID, log); err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		writePlaylist(w, r, log, storage, id, op)
+		log.Info("song successfully removed from playlist", "id", id, "id_song", songID)
+	}
+}
+
+// MovePlaylistSongHandler godoc
+// @Summary Move a song within a playlist
+// @Description Move a song already in the playlist to the given 1-based position; a position past the end moves it last
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param id query int true "Playlist ID"
+// @Param song body PlaylistSongRequest true "Song ID and new position"
+// @Success 200 {object} postgres.PlaylistDetails
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Playlist not found or song not in it"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Playlist/order [put]
+func MovePlaylistSongHandler(log *slog.Logger, storage storage.PlaylistStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.MovePlaylistSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		req, ok := decodePlaylistSong(w, r, log, op)
+		if !ok {
+			return
+		}
+		if req.Position < 1 {
+			request.Write(w, request.Invalid("position", "position must be at least 1"))
+			return
+		}
+
+		if err := storage.MovePlaylistSong(r.Context(), id, req.SongID, req.Position, log); err != nil {
+			playlistError(w, log, op, err)
+			return
+		}
+
+		writePlaylist(w, r, log, storage, id, op)
+		log.Info("song successfully moved in playlist", "id", id, "id_song", req.SongID, "position", req.Position)
+	}
+}
+
+func decodePlaylistName(w http.ResponseWriter, r *http.Request, log *slog.Logger, op string) (string, bool) {
+	var req PlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Error decoding request body", "error", err, "operation", op)
+		request.Write(w, request.BadRequest("Error decoding request body"))
+		return "", false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		request.Write(w, request.Invalid("name", "name is required"))
+		return "", false
+	}
+	return name, true
+}
+
+func decodePlaylistSong(w http.ResponseWriter, r *http.Request, log *slog.Logger, op string) (PlaylistSongRequest, bool) {
+	var req PlaylistSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Error("Error decoding request body", "error", err, "operation", op)
+		request.Write(w, request.BadRequest("Error decoding request body"))
+		return req, false
+	}
+
+	if req.SongID <= 0 || req.Position < 0 {
+		request.Write(w, request.BadRequest("songId is required and position may not be negative"))
+		return req, false
+	}
+	return req, true
+}
+
+// writePlaylist answers a change to a playlist with its new contents.
+func writePlaylist(w http.ResponseWriter, r *http.Request, log *slog.Logger, storage storage.PlaylistStore, id int, op string) {
+	playlist, err := storage.GetPlaylist(r.Context(), id, log)
+	if err != nil {
+		playlistError(w, log, op, err)
+		return
+	}
+	json.NewEncoder(w).Encode(playlist)
+}
+
+func playlistError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrPlaylistNotFound):
+		problem = request.Error(http.StatusNotFound, "Error playlist not found").WithCode(request.CodePlaylistNotFound)
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrSongNotInList):
+		problem = request.Error(http.StatusNotFound, "Error song is not in the playlist").WithCode(request.CodeSongNotInPlaylist)
+	case errors.Is(err, postgres.ErrSongInPlaylist):
+		problem = request.Error(http.StatusConflict, "Error song is already in the playlist").WithCode(request.CodeDuplicatePlaylistSong)
+	default:
+		log.Error("Error in playlist storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
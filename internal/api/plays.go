@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/plays"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"time"
+)
+
+type PlayRequest struct {
+	SongID int `json:"songId"`
+	// PlayedAt defaults to the time the play is received.
+	PlayedAt *time.Time `json:"playedAt"`
+	// Duration is how many seconds were listened to.
+	Duration *int `json:"duration"`
+}
+
+// maxClockSkew is how far in the future a play may be dated, for clients whose clock is ahead.
+const maxClockSkew = time.Minute
+
+// maxPlayAge is how far in the past a play may be dated, for clients sending plays they
+// recorded offline.
+const maxPlayAge = 365 * 24 * time.Hour
+
+// maxPlayDuration is the most seconds a single play may last.
+const maxPlayDuration = 24 * 60 * 60
+
+// RecordPlayHandler godoc
+// @Summary Record a play
+// @Description Log that a song was played. Plays are written in batches, so they show in statistics after a short delay; plays of songs not in the library are dropped.
+// @Tags plays
+// @Accept json
+// @Produce json
+// @Param play body PlayRequest true "Song ID, optional play time and seconds listened"
+// @Success 202 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 503 {object} request.ErrorResponse "Too many plays queued"
+// @Router /songLibrary/Play [post]
+func RecordPlayHandler(log *slog.Logger, recorder *plays.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RecordPlayHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var req PlayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		now := time.Now()
+
+		play := postgres.Play{SongID: req.SongID, PlayedAt: now, Duration: req.Duration}
+		if req.PlayedAt != nil {
+			play.PlayedAt = *req.PlayedAt
+		}
+
+		switch {
+		case play.SongID <= 0:
+			request.Write(w, request.Invalid("songId", "songId is required"))
+			return
+		case play.SongID > math.MaxInt32:
+			request.Write(w, request.Invalid("songId", "songId is out of range"))
+			return
+		case play.Duration != nil && *play.Duration < 0:
+			request.Write(w, request.Invalid("duration", "duration must not be negative"))
+			return
+		case play.Duration != nil && *play.Duration > maxPlayDuration:
+			request.Write(w, request.Invalid("duration", fmt.Sprintf("duration must be at most %d seconds", maxPlayDuration)))
+			return
+		case play.PlayedAt.After(now.Add(maxClockSkew)):
+			request.Write(w, request.Invalid("playedAt", "playedAt must not be in the future"))
+			return
+		case play.PlayedAt.Before(now.Add(-maxPlayAge)):
+			request.Write(w, request.Invalid("playedAt", "playedAt must be within the last year"))
+			return
+		}
+
+		if err := recorder.Record(play); err != nil {
+			if errors.Is(err, plays.ErrQueueFull) {
+				log.Warn("Play queue is full", "operation", op)
+				w.Header().Set("Retry-After", "1")
+				request.Write(w, request.Error(http.StatusServiceUnavailable, "Error too many plays, try again later"))
+				return
+			}
+			log.Error("Error recording play", "error", err, "operation", op)
+			request.Write(w, storageProblem(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(request.Accepted())
+		log.Debug("play queued", "id_song", play.SongID)
+	}
+}
+
+// RecentPlaysHandler godoc
+// @Summary Recently played songs
+// @Description List the latest plays, most recent first
+// @Tags plays
+// @Produce json
+// @Param limit query int false "Number of plays (default 50, max 500)"
+// @Success 200 {array} postgres.RecentPlay
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Plays/recent [get]
+func RecentPlaysHandler(log *slog.Logger, storage storage.PlayStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RecentPlaysHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parsePlayQuery(r)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		recent, err := storage.RecentPlays(r.Context(), q.Limit, log)
+		if err != nil {
+			playError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(recent)
+		log.Info("recent plays successfully received", "count", len(recent))
+	}
+}
+
+// MostPlayedSongsHandler godoc
+// @Summary Most played songs
+// @Description List the songs played most often in a window, with the seconds listened
+// @Tags plays
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default now)"
+// @Param limit query int false "Number of songs (default 50, max 500)"
+// @Success 200 {array} postgres.PlayedSong
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Stats/songs [get]
+func MostPlayedSongsHandler(log *slog.Logger, storage storage.PlayStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.MostPlayedSongsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parsePlayQuery(r)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		songs, err := storage.MostPlayedSongs(r.Context(), q, log)
+		if err != nil {
+			playError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(songs)
+		log.Info("most played songs successfully received", "count", len(songs))
+	}
+}
+
+// MostPlayedArtistsHandler godoc
+// @Summary Most played artists
+// @Description List the artists whose songs were played most often in a window
+// @Tags plays
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default now)"
+// @Param limit query int false "Number of artists (default 50, max 500)"
+// @Success 200 {array} postgres.PlayedArtist
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Stats/artists [get]
+func MostPlayedArtistsHandler(log *slog.Logger, storage storage.PlayStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.MostPlayedArtistsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parsePlayQuery(r)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		artists, err := storage.MostPlayedArtists(r.Context(), q, log)
+		if err != nil {
+			playError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(artists)
+		log.Info("most played artists successfully received", "count", len(artists))
+	}
+}
+
+// DailyPlaysHandler godoc
+// @Summary Plays per day
+// @Description Count the plays of every UTC day in a window, days without plays included
+// @Tags plays
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default now)"
+// @Success 200 {array} postgres.DayPlays
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Stats/daily [get]
+func DailyPlaysHandler(log *slog.Logger, storage storage.PlayStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.DailyPlaysHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parsePlayQuery(r)
+		if err == nil && len(q.Days()) > postgres.MaxPlayDays {
+			err = errors.New("the window may be at most 366 days")
+		}
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		days, err := storage.DailyPlays(r.Context(), q, log)
+		if err != nil {
+			playError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(days)
+		log.Info("daily plays successfully received", "days", len(days))
+	}
+}
+
+func playError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	log.Error("Error in play storage", "error", err, "operation", op)
+	request.Write(w, storageProblem(err))
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"net"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage/postgres"
+)
+
+// Details of the problems storage errors are reported as. The error itself may name
+// tables, constraints or values, so it is only logged.
+const (
+	detailConflict    = "Error request conflicts with existing data"
+	detailRejected    = "Error database rejected a value of the request"
+	detailUnavailable = "Error database is unavailable"
+	detailInternal    = "Error internal server error"
+)
+
+// pqStatuses maps the PostgreSQL errors a request can cause to the status, code and detail
+// of the problem they are reported as.
+var pqStatuses = map[string]struct {
+	status int
+	code   string
+	detail string
+}{
+	"unique_violation":             {http.StatusConflict, request.CodeConflict, detailConflict},
+	"foreign_key_violation":        {http.StatusConflict, request.CodeConflict, detailConflict},
+	"exclusion_violation":          {http.StatusConflict, request.CodeConflict, detailConflict},
+	"not_null_violation":           {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"check_violation":              {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"string_data_right_truncation": {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"invalid_text_representation":  {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"invalid_datetime_format":      {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"datetime_field_overflow":      {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"numeric_value_out_of_range":   {http.StatusBadRequest, request.CodeValidationFailed, detailRejected},
+	"too_many_connections":         {http.StatusServiceUnavailable, request.CodeDatabaseUnavailable, detailUnavailable},
+	"admin_shutdown":               {http.StatusServiceUnavailable, request.CodeDatabaseUnavailable, detailUnavailable},
+	"crash_shutdown":               {http.StatusServiceUnavailable, request.CodeDatabaseUnavailable, detailUnavailable},
+	"cannot_connect_now":           {http.StatusServiceUnavailable, request.CodeDatabaseUnavailable, detailUnavailable},
+}
+
+// storageProblem describes a storage error no handler expects: a PostgreSQL error by its
+// SQLSTATE, a database that cannot be reached as unavailable and anything else as internal.
+// The detail is generic; callers log err, and the request id in the problem finds the record.
+func storageProblem(err error) *request.ErrorResponse {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if mapped, ok := pqStatuses[pqErr.Code.Name()]; ok {
+			return request.Error(mapped.status, mapped.detail).WithCode(mapped.code)
+		}
+		if pqErr.Code.Class() == "08" {
+			return request.Error(http.StatusServiceUnavailable, detailUnavailable).WithCode(request.CodeDatabaseUnavailable)
+		}
+		return request.InternalServer(detailInternal)
+	}
+
+	var netErr *net.OpError
+	if errors.As(err, &netErr) || errors.Is(err, sql.ErrConnDone) {
+		return request.Error(http.StatusServiceUnavailable, detailUnavailable).WithCode(request.CodeDatabaseUnavailable)
+	}
+
+	return request.InternalServer(detailInternal)
+}
+
+// invalidError is a validation failure of one field or parameter of a request.
+type invalidError struct {
+	field   string
+	message string
+}
+
+func (e *invalidError) Error() string {
+	return e.message
+}
+
+func invalid(field, message string) error {
+	return &invalidError{field: field, message: message}
+}
+
+// validationProblem describes an error in a request, naming the field it is about when
+// the error knows it.
+func validationProblem(err error) *request.ErrorResponse {
+	var invalidErr *invalidError
+	if errors.As(err, &invalidErr) {
+		return request.Invalid(invalidErr.field, invalidErr.message)
+	}
+	var queryErr *postgres.InvalidError
+	if errors.As(err, &queryErr) {
+		return request.Invalid(queryErr.Field, queryErr.Message)
+	}
+	return request.BadRequest(err.Error())
+}
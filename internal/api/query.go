@@ -0,0 +1,232 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage/postgres"
+	"strconv"
+	"time"
+)
+
+// parseLibraryQuery reads the filter, sort and paging parameters shared by the library listings:
+// group, song, tag, genre, released_from, released_to, has_link, album_dates, sort, order, limit,
+// offset and cursor.
+func parseLibraryQuery(r *http.Request) (postgres.LibraryQuery, error) {
+	params := r.URL.Query()
+
+	q := postgres.LibraryQuery{
+		Group:  params.Get("group"),
+		Song:   params.Get("song"),
+		Tag:    params.Get("tag"),
+		Genre:  params.Get("genre"),
+		SortBy: params.Get("sort"),
+	}
+
+	var err error
+
+	if q.ReleasedFrom, err = parseDateParam(params.Get("released_from"), "released_from"); err != nil {
+		return q, err
+	}
+	if q.ReleasedTo, err = parseDateParam(params.Get("released_to"), "released_to"); err != nil {
+		return q, err
+	}
+
+	if v := params.Get("has_link"); v != "" {
+		hasLink, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, invalid("has_link", "has_link must be true or false")
+		}
+		q.HasLink = &hasLink
+	}
+
+	if v := params.Get("album_dates"); v != "" {
+		if q.AlbumDates, err = strconv.ParseBool(v); err != nil {
+			return q, invalid("album_dates", "album_dates must be true or false")
+		}
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, invalid("order", "order must be asc or desc")
+	}
+
+	if q.Limit, err = parseIntParam(params.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseIntParam(params.Get("offset"), "offset"); err != nil {
+		return q, err
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		if q.Cursor, err = postgres.DecodeCursor(v); err != nil {
+			return q, err
+		}
+	}
+
+	return q, q.Normalize()
+}
+
+// parseAlbumQuery reads the artist, type, limit and offset parameters of the album listing.
+func parseAlbumQuery(r *http.Request) (postgres.AlbumQuery, error) {
+	params := r.URL.Query()
+
+	q := postgres.AlbumQuery{Type: params.Get("type")}
+
+	var err error
+
+	if q.ArtistID, err = parseIntParam(params.Get("artist"), "artist"); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseIntParam(params.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseIntParam(params.Get("offset"), "offset"); err != nil {
+		return q, err
+	}
+
+	return q, q.Normalize()
+}
+
+// parseTopRatedQuery reads the artist, group, year and limit parameters of the top rated listing.
+func parseTopRatedQuery(r *http.Request) (postgres.TopRatedQuery, error) {
+	params := r.URL.Query()
+
+	q := postgres.TopRatedQuery{Group: params.Get("group")}
+
+	var err error
+
+	if q.ArtistID, err = parseIntParam(params.Get("artist"), "artist"); err != nil {
+		return q, err
+	}
+	if q.Year, err = parseIntParam(params.Get("year"), "year"); err != nil {
+		return q, err
+	}
+	if q.Year < 0 {
+		return q, invalid("year", "year must not be negative")
+	}
+	if q.Limit, err = parseIntParam(params.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+
+	q.Normalize()
+	return q, nil
+}
+
+// parsePlayQuery reads the from, to and limit parameters of the play statistics. Both dates
+// are whole UTC days and to includes its day.
+func parsePlayQuery(r *http.Request) (postgres.PlayQuery, error) {
+	params := r.URL.Query()
+
+	var q postgres.PlayQuery
+
+	from, err := parseDateParam(params.Get("from"), "from")
+	if err != nil {
+		return q, err
+	}
+	if from != nil {
+		q.From = *from
+	}
+
+	to, err := parseDateParam(params.Get("to"), "to")
+	if err != nil {
+		return q, err
+	}
+	if to != nil {
+		q.To = to.AddDate(0, 0, 1)
+	}
+
+	if q.Limit, err = parseIntParam(params.Get("limit"), "limit"); err != nil {
+		return q, err
+	}
+
+	return q, q.Normalize()
+}
+
+func parseDateParam(v, name string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return nil, invalid(name, fmt.Sprintf("%s must be a date in YYYY-MM-DD format", name))
+	}
+
+	return &t, nil
+}
+
+func parseIntParam(v, name string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, invalid(name, fmt.Sprintf("%s must be an integer", name))
+	}
+
+	return n, nil
+}
+
+const (
+	lyricsText   = "text"
+	lyricsVerses = "verses"
+	lyricsLines  = "lines"
+
+	maxLyricsPerPage = 100
+)
+
+// parseLyricsQuery reads format, page and per_page for TextSongHandler. Asking for a page
+// without a format means verses; with no parameters at all the lyrics stay a single string.
+func parseLyricsQuery(r *http.Request) (string, int, int, error) {
+	params := r.URL.Query()
+
+	page, err := parseIntParam(params.Get("page"), "page")
+	if err != nil {
+		return "", 0, 0, err
+	}
+	perPage, err := parseIntParam(params.Get("per_page"), "per_page")
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	format := params.Get("format")
+	switch format {
+	case "":
+		format = lyricsText
+		if params.Has("page") || params.Has("per_page") {
+			format = lyricsVerses
+		}
+	case lyricsText, lyricsVerses, lyricsLines:
+	default:
+		return "", 0, 0, invalid("format", "format must be text, verses or lines")
+	}
+
+	if !params.Has("page") {
+		page = 1
+	}
+	if page < 1 {
+		return "", 0, 0, invalid("page", "page must be 1 or greater")
+	}
+	if perPage < 0 || perPage > maxLyricsPerPage || (params.Has("per_page") && perPage == 0) {
+		return "", 0, 0, invalid("per_page", fmt.Sprintf("per_page must be between 1 and %d", maxLyricsPerPage))
+	}
+
+	return format, page, perPage, nil
+}
+
+// queryID reads the required id query parameter and answers 400 when it is missing or not a number.
+func queryID(w http.ResponseWriter, r *http.Request, log *slog.Logger, op string) (int, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		log.Error("no id or transmitted incorrectly", "error", err, "operation", op)
+		request.Write(w, request.Invalid("id", "no id or transmitted incorrectly"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+)
+
+type RateSongRequest struct {
+	Stars int `json:"stars"`
+}
+
+// FavoritesHandler godoc
+// @Summary List favorite songs
+// @Description List the favorites of the user making the request, most recently added first
+// @Tags ratings
+// @Produce json
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {array} postgres.Favorite
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Favorites [get]
+func FavoritesHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.FavoritesHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		favorites, err := storage.ListFavorites(r.Context(), user, log)
+		if err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(favorites)
+		log.Info("favorites successfully received", "user", user)
+	}
+}
+
+// AddFavoriteHandler godoc
+// @Summary Mark a song as favorite
+// @Description Add a song to the favorites of the user making the request; adding it again changes nothing
+// @Tags ratings
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 404 {object} request.ErrorResponse "Song not found"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Favorites [put]
+func AddFavoriteHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AddFavoriteHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.AddFavorite(r.Context(), user, id, log); err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("favorite successfully added", "user", user, "id_song", id)
+	}
+}
+
+// RemoveFavoriteHandler godoc
+// @Summary Unmark a favorite song
+// @Description Remove a song from the favorites of the user making the request
+// @Tags ratings
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Favorites [delete]
+func RemoveFavoriteHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RemoveFavoriteHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.RemoveFavorite(r.Context(), user, id, log); err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("favorite successfully removed", "user", user, "id_song", id)
+	}
+}
+
+// RatingsHandler godoc
+// @Summary List rated songs
+// @Description List the ratings of the user making the request, most recently rated first
+// @Tags ratings
+// @Produce json
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {array} postgres.Rating
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Ratings [get]
+func RatingsHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RatingsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		ratings, err := storage.ListRatings(r.Context(), user, log)
+		if err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(ratings)
+		log.Info("ratings successfully received", "user", user)
+	}
+}
+
+// RateSongHandler godoc
+// @Summary Rate a song
+// @Description Give a song 1 to 5 stars, replacing an earlier rating of the user making the request
+// @Tags ratings
+// @Accept json
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param rating body RateSongRequest true "Stars from 1 to 5"
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 404 {object} request.ErrorResponse "Song not found"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Rating [put]
+func RateSongHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RateSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var req RateSongRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		if !postgres.ValidStars(req.Stars) {
+			request.Write(w, request.Invalid("stars", "stars must be from 1 to 5"))
+			return
+		}
+
+		if err := storage.RateSong(r.Context(), user, id, req.Stars, log); err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("song successfully rated", "user", user, "id_song", id, "stars", req.Stars)
+	}
+}
+
+// RemoveRatingHandler godoc
+// @Summary Remove a rating
+// @Description Remove the rating the user making the request gave a song
+// @Tags ratings
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param X-User-Id header string false "User, honored only behind the trusted proxy"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 401 {object} request.ErrorResponse "No user"
+// @Failure 404 {object} request.ErrorResponse "Song not rated"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Rating [delete]
+func RemoveRatingHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RemoveRatingHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		user, ok := currentUser(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.RemoveRating(r.Context(), user, id, log); err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("rating successfully removed", "user", user, "id_song", id)
+	}
+}
+
+// TopRatedHandler godoc
+// @Summary Top rated songs
+// @Description List the songs with the best average rating of all users; ties go to the song rated more often
+// @Tags ratings
+// @Produce json
+// @Param artist query int false "Artist ID"
+// @Param group query string false "Artist name or alias"
+// @Param year query int false "Release year"
+// @Param limit query int false "Number of songs (default 50, max 500)"
+// @Success 200 {array} postgres.RatedSong
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/TopRated [get]
+func TopRatedHandler(log *slog.Logger, storage storage.RatingStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.TopRatedHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		q, err := parseTopRatedQuery(r)
+		if err != nil {
+			request.Write(w, validationProblem(err))
+			return
+		}
+
+		songs, err := storage.TopRated(r.Context(), q, log)
+		if err != nil {
+			ratingError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(songs)
+		log.Info("top rated songs successfully received", "count", len(songs))
+	}
+}
+
+// currentUser returns the user favorites and ratings are kept for, writing 401 when the
+// request names none and 400 when the name is too long to store.
+func currentUser(w http.ResponseWriter, r *http.Request, log *slog.Logger, op string) (string, bool) {
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		log.Warn("Request without user", "path", r.URL.Path, "operation", op)
+		request.Write(w, request.Error(http.StatusUnauthorized, "Error user required: authenticate with an api key"))
+		return "", false
+	}
+
+	if len(user) > postgres.MaxUserIDLength {
+		request.Write(w, request.BadRequest("user id may be at most 100 characters"))
+		return "", false
+	}
+
+	return user, true
+}
+
+func ratingError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrNotRated):
+		problem = request.Error(http.StatusNotFound, "Error song is not rated").WithCode(request.CodeNotRated)
+	default:
+		log.Error("Error in rating storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
@@ -0,0 +1,68 @@
+package request
+
+import "net/http"
+
+// Codes of problems. A code is part of the API: once published it keeps its meaning.
+const (
+	CodeValidationFailed     = "VALIDATION_FAILED"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotFound             = "NOT_FOUND"
+	CodeNotAcceptable        = "NOT_ACCEPTABLE"
+	CodeConflict             = "CONFLICT"
+	CodePreconditionFailed   = "PRECONDITION_FAILED"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeInternal             = "INTERNAL_ERROR"
+	CodeBadGateway           = "BAD_GATEWAY"
+	CodeUnavailable          = "SERVICE_UNAVAILABLE"
+
+	CodeSongNotFound          = "SONG_NOT_FOUND"
+	CodeDuplicateSong         = "DUPLICATE_SONG"
+	CodeSongInTrash           = "SONG_IN_TRASH"
+	CodeSongNotInCatalog      = "SONG_NOT_IN_CATALOG"
+	CodeVersionMismatch       = "VERSION_MISMATCH"
+	CodeRevisionNotFound      = "REVISION_NOT_FOUND"
+	CodeRevisionCurrent       = "REVISION_CURRENT"
+	CodeLyricsTooLong         = "LYRICS_TOO_LONG"
+	CodeArtistNotFound        = "ARTIST_NOT_FOUND"
+	CodeDuplicateArtist       = "DUPLICATE_ARTIST"
+	CodePlaylistNotFound      = "PLAYLIST_NOT_FOUND"
+	CodeSongNotInPlaylist     = "SONG_NOT_IN_PLAYLIST"
+	CodeDuplicatePlaylistSong = "DUPLICATE_PLAYLIST_SONG"
+	CodeAlbumNotFound         = "ALBUM_NOT_FOUND"
+	CodeDuplicateAlbum        = "DUPLICATE_ALBUM"
+	CodeGenreNotFound         = "GENRE_NOT_FOUND"
+	CodeDuplicateGenre        = "DUPLICATE_GENRE"
+	CodeNotRated              = "NOT_RATED"
+	CodeKeyNotFound           = "KEY_NOT_FOUND"
+
+	CodeCatalogUnavailable  = "CATALOG_UNAVAILABLE"
+	CodeCatalogBadResponse  = "CATALOG_BAD_RESPONSE"
+	CodeDatabaseUnavailable = "DATABASE_UNAVAILABLE"
+)
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeValidationFailed,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusNotAcceptable:         CodeNotAcceptable,
+	http.StatusConflict:              CodeConflict,
+	http.StatusPreconditionFailed:    CodePreconditionFailed,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusBadGateway:            CodeBadGateway,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// statusCode is the code of a problem nothing more precise is known about than its status.
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status < http.StatusInternalServerError {
+		return CodeValidationFailed
+	}
+	return CodeInternal
+}
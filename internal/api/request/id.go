@@ -0,0 +1,43 @@
+package request
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// HeaderRequestID carries the id of a request. A client may choose it; otherwise one is
+// made up. Every response sends it back.
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDLength bounds the ids clients choose, as they end up in logs and responses.
+const maxRequestIDLength = 128
+
+// RequestID gives every request an id and sets it on the response before the handler runs,
+// which is where Write finds it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if id == "" || len(id) > maxRequestIDLength || !printable(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	var raw [16]byte
+	rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
+
+func printable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,28 +1,96 @@
 package request
 
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
 const (
 	OkReq             = "Ok"
-	badReq            = "Bad Request"
 	InternalServerReq = "Internal Server Error"
 )
 
+// ProblemType is the media type error responses are sent as (RFC 7807).
+const ProblemType = "application/problem+json"
+
 type OkResponse struct {
 	Description string `json:"description"`
 }
 
+// ErrorResponse is a problem detail (RFC 7807). Code is a stable name of the error clients
+// can branch on, unlike the free-text detail. Errors lists the fields a validation failure
+// is about, and RequestID names the request in the logs. Description and Error repeat title
+// and detail for clients written before problem details.
 type ErrorResponse struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
 	Description string `json:"description"`
 	Error       string `json:"error"`
 }
 
+// FieldError is what is wrong with one field or parameter of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 func Ok() *OkResponse {
 	return &OkResponse{Description: OkReq}
 }
 
+// Accepted answers a request that was queued rather than carried out yet.
+func Accepted() *OkResponse {
+	return &OkResponse{Description: http.StatusText(http.StatusAccepted)}
+}
+
 func BadRequest(err string) *ErrorResponse {
-	return &ErrorResponse{Description: badReq, Error: err}
+	return Error(http.StatusBadRequest, err)
+}
+
+// Invalid is a validation failure of one field or parameter.
+func Invalid(field, err string) *ErrorResponse {
+	problem := BadRequest(err)
+	problem.Errors = []FieldError{{Field: field, Message: err}}
+	return problem
+}
+
+// Error builds an error response for any status, described by its standard status text and
+// coded after the status until WithCode names the error more precisely.
+func Error(status int, err string) *ErrorResponse {
+	p := &ErrorResponse{
+		Title:       http.StatusText(status),
+		Status:      status,
+		Detail:      err,
+		Description: http.StatusText(status),
+		Error:       err,
+	}
+	return p.WithCode(statusCode(status))
 }
 
 func InternalServer(err string) *ErrorResponse {
-	return &ErrorResponse{Description: InternalServerReq, Error: err}
+	return Error(http.StatusInternalServerError, err)
+}
+
+// WithCode sets the code of the problem and the type derived from it.
+func (p *ErrorResponse) WithCode(code string) *ErrorResponse {
+	p.Code = code
+	p.Type = "urn:songLibrary:problem:" + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+	return p
+}
+
+// Write sends a problem with its status as application/problem+json, together with the id
+// RequestID gave the request.
+func Write(w http.ResponseWriter, p *ErrorResponse) {
+	p.RequestID = w.Header().Get(HeaderRequestID)
+
+	w.Header().Set("Content-Type", ProblemType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
 }
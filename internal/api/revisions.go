@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/lyrics"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strconv"
+)
+
+// RevisionDiff is the difference between two revisions of the info of a song: the fields
+// that differ and the lyrics line by line.
+type RevisionDiff struct {
+	SongID        int               `json:"songId"`
+	From          int               `json:"from"`
+	To            int               `json:"to"`
+	ChangedFields []string          `json:"changedFields"`
+	Lines         []lyrics.DiffLine `json:"lines"`
+}
+
+// RevisionsHandler godoc
+// @Summary List the revisions of a song
+// @Description List every change to the release date, lyrics and link of a song, newest first, with who made it and which fields it changed. Revision 1 is the info before the first change.
+// @Tags revisions
+// @Produce json
+// @Param id query int true "Song ID"
+// @Success 200 {array} postgres.Revision
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Revisions [get]
+func RevisionsHandler(log *slog.Logger, storage storage.RevisionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RevisionsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		revisions, err := storage.ListRevisions(r.Context(), id, log)
+		if err != nil {
+			revisionError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(revisions)
+		log.Info("revisions successfully received", "id_song", id)
+	}
+}
+
+// RevisionDiffHandler godoc
+// @Summary Compare two revisions of a song
+// @Description Show the fields that differ between two revisions of a song and a line-by-line diff of their lyrics
+// @Tags revisions
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param from query int true "Revision to compare from"
+// @Param to query int true "Revision to compare to"
+// @Success 200 {object} RevisionDiff
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 422 {object} request.ErrorResponse "Lyrics too long to compare"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Revisions/diff [get]
+func RevisionDiffHandler(log *slog.Logger, storage storage.RevisionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RevisionDiffHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var revisions [2]postgres.Revision
+		for i, name := range []string{"from", "to"} {
+			number, err := strconv.Atoi(r.URL.Query().Get(name))
+			if err != nil {
+				request.Write(w, request.Invalid(name, "no "+name+" revision or transmitted incorrectly"))
+				return
+			}
+
+			if revisions[i], err = storage.GetRevision(r.Context(), id, number, log); err != nil {
+				revisionError(w, log, op, err)
+				return
+			}
+		}
+
+		from, to := revisions[0], revisions[1]
+
+		lines, err := lyrics.Diff(from.InfoSong.Text, to.InfoSong.Text)
+		if err != nil {
+			log.Warn("Error comparing lyrics", "error", err, "id_song", id, "operation", op)
+			request.Write(w, request.Error(http.StatusUnprocessableEntity,
+				fmt.Sprintf("Error lyrics have more than %d lines to compare", lyrics.MaxDiffLines)).WithCode(request.CodeLyricsTooLong))
+			return
+		}
+
+		json.NewEncoder(w).Encode(RevisionDiff{
+			SongID:        id,
+			From:          from.Revision,
+			To:            to.Revision,
+			ChangedFields: postgres.ChangedFields(from.InfoSong, to.InfoSong),
+			Lines:         lines,
+		})
+		log.Info("revisions successfully compared", "id_song", id, "from", from.Revision, "to", to.Revision)
+	}
+}
+
+// RollbackInfoHandler godoc
+// @Summary Roll back the info of a song
+// @Description Set the release date, lyrics and link of a song back to a revision. The rollback is recorded as a new revision. With If-Match the song must still have that ETag.
+// @Tags revisions
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param revision query int true "Revision to roll back to"
+// @Param If-Match header string false "ETag of the song the rollback is made against"
+// @Success 200 {object} postgres.Revision
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 409 {object} request.ErrorResponse "Info already matches the revision"
+// @Failure 412 {object} request.ErrorResponse "Song changed since the ETag in If-Match"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Revisions/rollback [post]
+func RollbackInfoHandler(log *slog.Logger, revisions storage.RevisionStore, songs storage.SongStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RollbackInfoHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		revision, err := strconv.Atoi(r.URL.Query().Get("revision"))
+		if err != nil {
+			request.Write(w, request.Invalid("revision", "no revision or transmitted incorrectly"))
+			return
+		}
+
+		editor, ok := requestEditor(w, r)
+		if !ok {
+			return
+		}
+
+		ifVersion, err := ifMatchVersion(r, songs, id, log)
+		if err != nil {
+			revisionError(w, log, op, err)
+			return
+		}
+
+		rev, err := revisions.RollbackInfo(r.Context(), id, revision, editor, ifVersion, log)
+		if err != nil {
+			revisionError(w, log, op, err)
+			return
+		}
+
+		w.Header().Set("ETag", songETag(rev.Version))
+		json.NewEncoder(w).Encode(rev)
+		log.Info("song info rolled back", "id_song", id, "to", revision, "revision", rev.Revision)
+	}
+}
+
+// requestEditor returns the user a change is recorded under: empty when the request has
+// none, as when authentication is disabled.
+func requestEditor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user, _ := auth.UserFromContext(r.Context())
+	if len(user) > postgres.MaxUserIDLength {
+		request.Write(w, request.BadRequest("user id may be at most 100 characters"))
+		return "", false
+	}
+	return user, true
+}
+
+func revisionError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrRevisionNotFound):
+		problem = request.Error(http.StatusNotFound, "Error revision not found").WithCode(request.CodeRevisionNotFound)
+	case errors.Is(err, postgres.ErrRevisionCurrent):
+		problem = request.Error(http.StatusConflict, "Error song info already matches the revision").WithCode(request.CodeRevisionCurrent)
+	case errors.Is(err, postgres.ErrVersionMismatch):
+		problem = request.Error(http.StatusPreconditionFailed, "Error song changed since the ETag in If-Match").WithCode(request.CodeVersionMismatch)
+	default:
+		log.Error("Error in revision storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
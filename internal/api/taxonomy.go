@@ -0,0 +1,311 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strconv"
+	"strings"
+)
+
+type CreateGenreRequest struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parentId"`
+}
+
+type SongGenreRequest struct {
+	GenreID int `json:"genreId"`
+}
+
+type SongTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// GenresHandler godoc
+// @Summary List genres
+// @Description List all genres; subgenres name their parent in parentId
+// @Tags taxonomy
+// @Produce json
+// @Success 200 {array} postgres.Genre
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Genres [get]
+func GenresHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.GenresHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		genres, err := storage.ListGenres(r.Context(), log)
+		if err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(genres)
+		log.Info("genres successfully received")
+	}
+}
+
+// CreateGenreHandler godoc
+// @Summary Create a genre
+// @Description Create a genre, or a subgenre of parentId
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param genre body CreateGenreRequest true "Genre name and optional parent"
+// @Success 201 {object} postgres.Genre
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Parent genre not found"
+// @Failure 409 {object} request.ErrorResponse "Genre already exists"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Genre [post]
+func CreateGenreHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.CreateGenreHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var req CreateGenreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > postgres.MaxTagLength {
+			request.Write(w, request.Invalid("name", "name is required and may be at most 50 characters"))
+			return
+		}
+
+		genre, err := storage.CreateGenre(r.Context(), req.Name, req.ParentID, log)
+		if err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(genre)
+		log.Info("genre successfully created", "id", genre.ID)
+	}
+}
+
+// AddSongGenreHandler godoc
+// @Summary Assign a genre to a song
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param genre body SongGenreRequest true "Genre ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Song or genre not found"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song/genres [post]
+func AddSongGenreHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AddSongGenreHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var req SongGenreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		if err := storage.AddSongGenre(r.Context(), id, req.GenreID, log); err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("genre successfully assigned", "id_song", id, "id_genre", req.GenreID)
+	}
+}
+
+// RemoveSongGenreHandler godoc
+// @Summary Remove a genre from a song
+// @Tags taxonomy
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param genre query int true "Genre ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song/genres [delete]
+func RemoveSongGenreHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RemoveSongGenreHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		genreID, err := strconv.Atoi(r.URL.Query().Get("genre"))
+		if err != nil {
+			log.Error("no genre or transmitted incorrectly", "error", err, "operation", op)
+			request.Write(w, request.Invalid("genre", "no genre or transmitted incorrectly"))
+			return
+		}
+
+		if err = storage.RemoveSongGenre(r.Context(), id, genreID, log); err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("genre successfully removed", "id_song", id, "id_genre", genreID)
+	}
+}
+
+// AddSongTagsHandler godoc
+// @Summary Tag a song
+// @Description Attach free-form tags to a song. Tags are stored in lower case; tags the song already has are ignored.
+// @Tags taxonomy
+// @Accept json
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param tags body SongTagsRequest true "Tags"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse "Song not found"
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song/tags [post]
+func AddSongTagsHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.AddSongTagsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		var req SongTagsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("Error decoding request body", "error", err, "operation", op)
+			request.Write(w, request.BadRequest("Error decoding request body"))
+			return
+		}
+
+		tags := make([]string, 0, len(req.Tags))
+		for _, tag := range req.Tags {
+			tag = postgres.NormalizeTag(tag)
+			if tag == "" || len(tag) > postgres.MaxTagLength {
+				request.Write(w, request.Invalid("tags", "tags must be non-empty and at most 50 characters"))
+				return
+			}
+			tags = append(tags, tag)
+		}
+
+		if err := storage.AddSongTags(r.Context(), id, tags, log); err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("song successfully tagged", "id_song", id, "tags", len(tags))
+	}
+}
+
+// RemoveSongTagHandler godoc
+// @Summary Remove a tag from a song
+// @Tags taxonomy
+// @Produce json
+// @Param id query int true "Song ID"
+// @Param tag query string true "Tag"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Song/tags [delete]
+func RemoveSongTagHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RemoveSongTagHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		tag := postgres.NormalizeTag(r.URL.Query().Get("tag"))
+		if tag == "" {
+			request.Write(w, request.Invalid("tag", "no tag transmitted"))
+			return
+		}
+
+		if err := storage.RemoveSongTag(r.Context(), id, tag, log); err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("tag successfully removed", "id_song", id, "tag", tag)
+	}
+}
+
+// TagsHandler godoc
+// @Summary Count tags
+// @Description List every tag with the number of library songs that have it, most used first
+// @Tags taxonomy
+// @Produce json
+// @Success 200 {array} postgres.TagCount
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Tags [get]
+func TagsHandler(log *slog.Logger, storage storage.TaxonomyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.TagsHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		counts, err := storage.TagCounts(r.Context(), log)
+		if err != nil {
+			taxonomyError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(counts)
+		log.Info("tag counts successfully received")
+	}
+}
+
+func taxonomyError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in library").WithCode(request.CodeSongNotFound)
+	case errors.Is(err, postgres.ErrGenreNotFound):
+		problem = request.Error(http.StatusNotFound, "Error genre not found").WithCode(request.CodeGenreNotFound)
+	case errors.Is(err, postgres.ErrGenreExists):
+		problem = request.Error(http.StatusConflict, "Error genre already exists").WithCode(request.CodeDuplicateGenre)
+	default:
+		log.Error("Error in taxonomy storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
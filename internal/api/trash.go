@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+)
+
+// TrashHandler godoc
+// @Summary List the trash
+// @Description List the deleted songs waiting to be restored or purged, most recently deleted first
+// @Tags trash
+// @Produce json
+// @Success 200 {array} postgres.TrashedSong
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Trash [get]
+func TrashHandler(log *slog.Logger, storage storage.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.TrashHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		trash, err := storage.ListTrash(r.Context(), log)
+		if err != nil {
+			trashError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(trash)
+		log.Info("trash successfully received")
+	}
+}
+
+// RestoreSongHandler godoc
+// @Summary Restore a song
+// @Description Move a deleted song back from the trash into the library, with its info, playlists, albums, genres, tags, ratings and plays
+// @Tags trash
+// @Produce json
+// @Param id query int true "Song ID"
+// @Success 200 {object} postgres.Songs
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Trash/restore [post]
+func RestoreSongHandler(log *slog.Logger, storage storage.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.RestoreSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		song, err := storage.RestoreSong(r.Context(), id, log)
+		if err != nil {
+			trashError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(song)
+		log.Info("song successfully restored", "id", id)
+	}
+}
+
+// PurgeSongHandler godoc
+// @Summary Purge a song
+// @Description Delete a song in the trash for good, together with its info and everything referencing it
+// @Tags trash
+// @Produce json
+// @Param id query int true "Song ID"
+// @Success 200 {object} request.OkResponse
+// @Failure 400 {object} request.ErrorResponse
+// @Failure 404 {object} request.ErrorResponse
+// @Failure 500 {object} request.ErrorResponse
+// @Router /songLibrary/Trash [delete]
+func PurgeSongHandler(log *slog.Logger, storage storage.TrashStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.api.PurgeSongHandler()"
+		log := tracing.Logger(r.Context(), log)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := queryID(w, r, log, op)
+		if !ok {
+			return
+		}
+
+		if err := storage.PurgeSong(r.Context(), id, log); err != nil {
+			trashError(w, log, op, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(request.Ok())
+		log.Info("song successfully purged", "id", id)
+	}
+}
+
+func trashError(w http.ResponseWriter, log *slog.Logger, op string, err error) {
+	var problem *request.ErrorResponse
+	switch {
+	case errors.Is(err, postgres.ErrSongNotFound):
+		problem = request.Error(http.StatusNotFound, "Error song not found in trash").WithCode(request.CodeSongNotFound)
+	default:
+		log.Error("Error in trash storage", "error", err, "operation", op)
+		problem = storageProblem(err)
+	}
+
+	request.Write(w, problem)
+}
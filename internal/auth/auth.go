@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/api/request"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"strconv"
+	"strings"
+)
+
+const (
+	RoleReader = "reader"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// rank orders the roles: each role may do everything the roles below it may.
+var rank = map[string]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+const (
+	keyPrefix    = "sl_"
+	keyBytes     = 32
+	prefixLength = 11
+)
+
+const (
+	HeaderAPIKey = "X-API-Key"
+
+	// HeaderUserID names the user a trusted proxy authenticated. It is ignored unless the
+	// authenticator trusts the proxy.
+	HeaderUserID = "X-User-Id"
+)
+
+func ValidRole(role string) bool {
+	_, ok := rank[role]
+	return ok
+}
+
+// GenerateKey returns a new random key, the prefix shown to identify it and the hash to store.
+func GenerateKey() (key, prefix, hash string, err error) {
+	raw := make([]byte, keyBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return "", "", "", err
+	}
+
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	return key, key[:prefixLength], Hash(key), nil
+}
+
+// Hash is the SHA-256 of a key in hex. Keys are random, so a fast hash is enough and lets
+// keys be looked up by their hash.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type ctxKey struct{}
+
+// FromContext returns the API key that authenticated the request, if any.
+func FromContext(ctx context.Context) (postgres.APIKey, bool) {
+	key, ok := ctx.Value(ctxKey{}).(postgres.APIKey)
+	return key, ok
+}
+
+type userKey struct{}
+
+// UserFromContext returns the id of the user the request was made for, if any. Favorites
+// and ratings are kept per user.
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok
+}
+
+// KeyUserID is the user id of requests authenticated with an API key and no proxy user.
+func KeyUserID(key postgres.APIKey) string {
+	return "key:" + strconv.Itoa(key.ID)
+}
+
+// Authenticator resolves API keys and enforces roles. When disabled every request passes.
+// With trustedProxy the user named in X-User-Id is taken as is, so it must only be set
+// when every request comes through a proxy that authenticates users and sets the header.
+type Authenticator struct {
+	keys         storage.KeyStore
+	enabled      bool
+	trustedProxy bool
+	log          *slog.Logger
+}
+
+func New(keys storage.KeyStore, enabled, trustedProxy bool, log *slog.Logger) *Authenticator {
+	return &Authenticator{keys: keys, enabled: enabled, trustedProxy: trustedProxy, log: log}
+}
+
+// Middleware looks up the key sent in X-API-Key or as an Authorization bearer token and
+// stores it in the request context together with the user the request is made for. An
+// unknown key is rejected with 401; a missing key is left for Require to decide.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.auth.Middleware()"
+
+		ctx := r.Context()
+		if a.trustedProxy {
+			if user := strings.TrimSpace(r.Header.Get(HeaderUserID)); user != "" {
+				ctx = context.WithValue(ctx, userKey{}, user)
+			}
+		}
+
+		secret := keyFromRequest(r)
+		if !a.enabled || secret == "" {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		key, err := a.keys.GetKeyByHash(ctx, Hash(secret), a.log)
+		if err != nil {
+			if errors.Is(err, postgres.ErrKeyNotFound) {
+				a.log.Warn("Unknown api key", "operation", op)
+				reject(w, http.StatusUnauthorized, "Error invalid api key")
+				return
+			}
+			a.log.Error("Error looking up api key", "error", err, "operation", op)
+			reject(w, http.StatusInternalServerError, "Error looking up api key")
+			return
+		}
+
+		ctx = context.WithValue(ctx, ctxKey{}, key)
+		if _, ok := UserFromContext(ctx); !ok {
+			ctx = context.WithValue(ctx, userKey{}, KeyUserID(key))
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Require lets a request through only if it was authenticated with at least the given role.
+func (a *Authenticator) Require(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			const op = "internal.auth.Require()"
+
+			if !a.enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, ok := FromContext(r.Context())
+			if !ok {
+				a.log.Warn("Request without api key", "path", r.URL.Path, "operation", op)
+				reject(w, http.StatusUnauthorized, "Error api key required")
+				return
+			}
+
+			if rank[key.Role] < rank[role] {
+				a.log.Warn("Api key role too low", "key", key.Prefix, "role", key.Role, "required", role, "operation", op)
+				reject(w, http.StatusForbidden, "Error api key role "+key.Role+" may not do this, "+role+" required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Bootstrap stores key as an admin key unless it is already known, so a fresh deployment
+// can be administered before any key was created through the API or the CLI.
+func (a *Authenticator) Bootstrap(key string) error {
+	hash := Hash(key)
+
+	_, err := a.keys.GetKeyByHash(context.Background(), hash, a.log)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, postgres.ErrKeyNotFound) {
+		return err
+	}
+
+	_, err = a.keys.CreateKey(context.Background(), "bootstrap", RoleAdmin, key[:min(prefixLength, len(key))], hash, a.log)
+	return err
+}
+
+func keyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+func reject(w http.ResponseWriter, status int, message string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="songLibrary"`)
+	}
+	request.Write(w, request.Error(status, message))
+}
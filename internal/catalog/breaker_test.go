@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const (
+		allow   = "allow"
+		success = "success"
+		failure = "failure"
+		release = "release"
+		wait    = "wait"
+	)
+
+	type step struct {
+		do   string
+		want bool // for allow: whether the call may go out
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+	}{
+		{
+			name:      "disabled never opens",
+			threshold: 0,
+			steps: []step{
+				{failure, false}, {failure, false}, {failure, false},
+				{allow, true},
+			},
+		},
+		{
+			name:      "closed below the threshold",
+			threshold: 3,
+			steps: []step{
+				{failure, false}, {failure, false},
+				{allow, true},
+			},
+		},
+		{
+			name:      "success resets the count",
+			threshold: 2,
+			steps: []step{
+				{failure, false}, {success, false}, {failure, false},
+				{allow, true},
+			},
+		},
+		{
+			name:      "opens at the threshold",
+			threshold: 2,
+			steps: []step{
+				{failure, false}, {failure, false},
+				{allow, false}, {allow, false},
+			},
+		},
+		{
+			name:      "single trial after the cooldown",
+			threshold: 1,
+			steps: []step{
+				{failure, false}, {wait, false},
+				{allow, true}, {allow, false},
+			},
+		},
+		{
+			name:      "successful trial closes",
+			threshold: 1,
+			steps: []step{
+				{failure, false}, {wait, false}, {allow, true}, {success, false},
+				{allow, true}, {allow, true},
+			},
+		},
+		{
+			name:      "failed trial reopens",
+			threshold: 1,
+			steps: []step{
+				{failure, false}, {wait, false}, {allow, true}, {failure, false},
+				{allow, false}, {wait, false}, {allow, true},
+			},
+		},
+		{
+			name:      "released trial lets another through",
+			threshold: 1,
+			steps: []step{
+				{failure, false}, {wait, false}, {allow, true}, {release, false},
+				{allow, true}, {allow, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := newBreaker(tt.threshold, time.Minute)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				switch s.do {
+				case allow:
+					if got := b.allow(); got != s.want {
+						t.Fatalf("step %d: allow() = %v, want %v", i, got, s.want)
+					}
+				case success:
+					b.success()
+				case failure:
+					b.failure()
+				case release:
+					b.release()
+				case wait:
+					now = now.Add(time.Minute)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"songLibrary/internal/config"
+	"songLibrary/internal/metrics"
+	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/tracing"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound means the catalog answered and does not know the song.
+	ErrNotFound = errors.New("song not found in catalog")
+	// ErrUnavailable means the catalog could not be reached, kept failing or timed out,
+	// or the circuit breaker is open.
+	ErrUnavailable = errors.New("catalog unavailable")
+	// ErrMalformed means the catalog answered with something that is not a song description.
+	ErrMalformed = errors.New("malformed catalog response")
+)
+
+// maxResponseSize bounds how much of a catalog response is read.
+const maxResponseSize = 1 << 20
+
+// Client looks songs up in the external catalog service.
+type Client struct {
+	baseURL      string
+	apiKey       string
+	http         *http.Client
+	retries      int
+	retryBackoff time.Duration
+	breaker      *breaker
+}
+
+func NewClient(cfg config.Catalog) *Client {
+	return &Client{
+		baseURL:      strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:       cfg.APIKey,
+		http:         &http.Client{Timeout: cfg.Timeout},
+		retries:      cfg.Retries,
+		retryBackoff: cfg.RetryBackoff,
+		breaker:      newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// GetInfo fetches the release date, lyrics and link of a song. Failed attempts that may
+// succeed on another try are retried with exponential backoff.
+func (c *Client) GetInfo(ctx context.Context, group, song string, log *slog.Logger) (postgres.InfoSong, error) {
+	ctx, span := tracing.Start(ctx, "catalog.GetInfo")
+	defer span.End()
+
+	start := time.Now()
+	info, err := c.lookup(ctx, group, song, log)
+	metrics.ObserveCatalog(outcome(err), time.Since(start))
+
+	span.SetAttributes(attribute.String("catalog.outcome", outcome(err)))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		tracing.Fail(span, err)
+	}
+	return info, err
+}
+
+// Ping checks the catalog answers. Any answer but a server error will do, so it is not
+// retried and leaves the circuit breaker alone.
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/info", nil)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+	return nil
+}
+
+// outcome names how a lookup went for the metrics.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.CatalogFound
+	case errors.Is(err, ErrNotFound):
+		return metrics.CatalogNotFound
+	case errors.Is(err, ErrUnavailable):
+		return metrics.CatalogUnavailable
+	case errors.Is(err, ErrMalformed):
+		return metrics.CatalogMalformed
+	}
+	return metrics.CatalogCanceled
+}
+
+func (c *Client) lookup(ctx context.Context, group, song string, log *slog.Logger) (postgres.InfoSong, error) {
+	const op = "internal.catalog.GetInfo()"
+
+	if !c.breaker.allow() {
+		log.Warn("catalog circuit breaker is open", "operation", op)
+		return postgres.InfoSong{}, fmt.Errorf("%w: circuit breaker is open", ErrUnavailable)
+	}
+
+	u := c.baseURL + "/info?group=" + url.QueryEscape(group) + "&song=" + url.QueryEscape(song)
+
+	var (
+		info postgres.InfoSong
+		err  error
+	)
+
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			wait := c.retryBackoff << (attempt - 1)
+			log.Warn("retrying catalog request", "attempt", attempt, "wait", wait, "error", err, "operation", op)
+
+			select {
+			case <-ctx.Done():
+				c.breaker.release()
+				return postgres.InfoSong{}, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		var retry bool
+		info, retry, err = c.getInfo(ctx, u)
+		if err == nil || !retry {
+			break
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		c.breaker.release()
+		return postgres.InfoSong{}, ctx.Err()
+	case errors.Is(err, ErrUnavailable):
+		c.breaker.failure()
+		log.Error("Error making request to catalog", "error", err, "operation", op)
+	case err != nil:
+		c.breaker.success()
+		log.Error("Error getting song from catalog", "error", err, "operation", op)
+	default:
+		c.breaker.success()
+	}
+
+	return info, err
+}
+
+// getInfo makes one request and reports whether a failure is worth retrying. Each request
+// has its own span, and the catalog is sent its trace context in traceparent.
+func (c *Client) getInfo(ctx context.Context, u string) (postgres.InfoSong, bool, error) {
+	var info postgres.InfoSong
+
+	ctx, span := tracing.Start(ctx, "GET /info",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet, semconv.URLFull(u)))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		tracing.Fail(span, err)
+		return info, false, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		tracing.Fail(span, err)
+		return info, true, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return info, false, ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return info, true, fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	default:
+		return info, false, fmt.Errorf("%w: unexpected status %d", ErrMalformed, resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&info); err != nil {
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return info, true, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return info, false, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	// Older catalogs answer 200 with an empty description for songs they do not have.
+	if info.ReleaseDate == nil {
+		return info, false, ErrNotFound
+	}
+
+	return info, false, nil
+}
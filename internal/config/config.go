@@ -9,8 +9,16 @@ import (
 
 type Config struct {
 	Env        string `yaml:"env" env-default:"local"`
+	Storage    string `yaml:"storage" env-default:"postgres"`
 	Database   `yaml:"db"`
 	HttpServer `yaml:"HttpServer"`
+	Catalog    Catalog `yaml:"catalog"`
+	Auth       Auth    `yaml:"auth"`
+	Plays      Plays   `yaml:"plays"`
+	Import     Import  `yaml:"import"`
+	Trash      Trash   `yaml:"trash"`
+	Tracing    Tracing `yaml:"tracing"`
+	Health     Health  `yaml:"health"`
 }
 
 type Database struct {
@@ -22,9 +30,80 @@ type Database struct {
 }
 
 type HttpServer struct {
-	Address     string        `yaml:"address" env-default:"8080"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"4"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60"`
+	Address           string        `yaml:"address" env-default:":8080"`
+	Timeout           time.Duration `yaml:"timeout" env-default:"4s"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
+
+	// ShutdownDelay is how long the service reports not ready on shutdown before it stops
+	// taking requests, so load balancers stop sending them first.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+}
+
+type Catalog struct {
+	BaseURL          string        `yaml:"base_url" env-default:"http://0.0.0.0:8081"`
+	APIKey           string        `yaml:"api_key" env:"CATALOG_API_KEY"`
+	Timeout          time.Duration `yaml:"timeout" env-default:"5s"`
+	Retries          int           `yaml:"retries" env-default:"2"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff" env-default:"200ms"`
+	BreakerThreshold int           `yaml:"breaker_threshold" env-default:"5"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env-default:"30s"`
+}
+
+// Plays are queued and written in batches of up to BatchSize, at least every FlushInterval.
+// While QueueSize plays wait, further plays are refused.
+type Plays struct {
+	BatchSize     int           `yaml:"batch_size" env-default:"500"`
+	FlushInterval time.Duration `yaml:"flush_interval" env-default:"1s"`
+	QueueSize     int           `yaml:"queue_size" env-default:"10000"`
+}
+
+// Import looks up to Workers rows of a bulk import in the catalog at a time. An import
+// may have at most MaxRows rows and is stopped after Timeout.
+type Import struct {
+	Workers int           `yaml:"workers" env-default:"4"`
+	MaxRows int           `yaml:"max_rows" env-default:"10000"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10m"`
+}
+
+// Trash keeps deleted songs for Retention before they are purged for good; trashed songs
+// are looked for every PurgeInterval.
+type Trash struct {
+	Retention     time.Duration `yaml:"retention" env:"TRASH_RETENTION" env-default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// Tracing exports spans with Exporter: "otlp" sends them over OTLP/HTTP to Endpoint, or to
+// the OTEL_EXPORTER_OTLP_* environment variables when it is empty; "stdout" writes them to
+// File, or the standard output when it is empty; "none" turns export off. SampleRatio of the
+// traces not started by a caller are kept; traces propagated in traceparent follow the caller.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint"`
+	File        string  `yaml:"file"`
+	ServiceName string  `yaml:"service_name" env-default:"songLibrary"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// Health gives each readiness check at most Timeout. The catalog is checked only with
+// CheckCatalog, since the service still answers everything but AddSong and imports
+// without it.
+type Health struct {
+	Timeout      time.Duration `yaml:"timeout" env-default:"2s"`
+	CheckCatalog bool          `yaml:"check_catalog" env:"HEALTH_CHECK_CATALOG"`
+}
+
+// Auth is on unless disabled: cleanenv applies env-default to every zero value, so a
+// default of true could never be turned off from the config file.
+// TrustedProxy takes the user favorites and ratings belong to from the X-User-Id header;
+// only turn it on behind a proxy that authenticates users and sets that header itself.
+type Auth struct {
+	Disabled     bool   `yaml:"disabled" env:"AUTH_DISABLED"`
+	TrustedProxy bool   `yaml:"trusted_proxy" env:"AUTH_TRUSTED_PROXY"`
+	BootstrapKey string `yaml:"bootstrap_key" env:"API_BOOTSTRAP_KEY"`
 }
 
 func MustLoad() *Config {
@@ -43,5 +122,12 @@ func MustLoad() *Config {
 		log.Fatal("Error reading config file", err)
 	}
 
+	// timeout is the default for the read, header and write timeouts not set on their own.
+	for _, t := range []*time.Duration{&cfg.ReadTimeout, &cfg.ReadHeaderTimeout, &cfg.WriteTimeout} {
+		if *t == 0 {
+			*t = cfg.Timeout
+		}
+	}
+
 	return &cfg
 }
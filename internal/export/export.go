@@ -0,0 +1,347 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"songLibrary/internal/storage/postgres"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatM3U    = "m3u"
+	FormatXSPF   = "xspf"
+)
+
+// DefaultFormat is used when neither a format nor an Accept header asks for one.
+const DefaultFormat = FormatCSV
+
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+	FormatM3U:    "audio/x-mpegurl",
+	FormatXSPF:   "application/xspf+xml",
+}
+
+// acceptTypes maps the media types an Accept header may ask for to formats.
+var acceptTypes = map[string]string{
+	"text/csv":                      FormatCSV,
+	"application/x-ndjson":          FormatNDJSON,
+	"application/jsonl":             FormatNDJSON,
+	"audio/x-mpegurl":               FormatM3U,
+	"audio/mpegurl":                 FormatM3U,
+	"application/vnd.apple.mpegurl": FormatM3U,
+	"application/xspf+xml":          FormatXSPF,
+}
+
+func ValidFormat(format string) bool {
+	_, ok := contentTypes[format]
+	return ok
+}
+
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FormatOf guesses a format from a file name.
+func FormatOf(name string) string {
+	ext := name[strings.LastIndex(name, ".")+1:]
+	switch ext = strings.ToLower(ext); ext {
+	case "jsonl":
+		return FormatNDJSON
+	case "m3u8":
+		return FormatM3U
+	}
+	if ValidFormat(ext) {
+		return ext
+	}
+	return ""
+}
+
+// Negotiate picks the format an Accept header prefers. An empty header or one accepting
+// anything gets the default format; ok is false when nothing acceptable is supported.
+func Negotiate(accept string) (format string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return DefaultFormat, true
+	}
+
+	type choice struct {
+		format string
+		q      float64
+	}
+
+	var choices []choice
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch f, known := acceptTypes[mediaType]; {
+		case known:
+			choices = append(choices, choice{f, q})
+		case mediaType == "*/*" || mediaType == "text/*":
+			choices = append(choices, choice{DefaultFormat, q})
+		}
+	}
+
+	if len(choices) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].format, true
+}
+
+// Writer encodes songs one at a time. Nothing is written before the first song or Close,
+// so an error before any song can still be reported instead of an export.
+type Writer interface {
+	Write(song postgres.Songs) error
+	// Close ends the document and flushes it.
+	Close() error
+}
+
+// NewWriter returns a writer of the format. Lyrics are left out unless asked for; M3U has
+// no place for them.
+func NewWriter(w io.Writer, format string, lyrics bool) (Writer, error) {
+	buf := bufio.NewWriter(w)
+
+	switch format {
+	case FormatCSV:
+		return &csvWriter{buf: buf, csv: csv.NewWriter(buf), lyrics: lyrics}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf), lyrics: lyrics}, nil
+	case FormatM3U:
+		return &m3uWriter{buf: buf}, nil
+	case FormatXSPF:
+		enc := xml.NewEncoder(buf)
+		enc.Indent("", "  ")
+		return &xspfWriter{buf: buf, enc: enc, lyrics: lyrics}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q, use csv, ndjson, m3u or xspf", format)
+}
+
+func releaseDate(song postgres.Songs) string {
+	if song.InfoSong.ReleaseDate == nil {
+		return ""
+	}
+	return song.InfoSong.ReleaseDate.Format(time.DateOnly)
+}
+
+type csvWriter struct {
+	buf     *bufio.Writer
+	csv     *csv.Writer
+	lyrics  bool
+	started bool
+}
+
+func (w *csvWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	header := []string{"id", "group", "song", "releaseDate", "link"}
+	if w.lyrics {
+		header = append(header, "text")
+	}
+	return w.csv.Write(header)
+}
+
+func (w *csvWriter) Write(song postgres.Songs) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	record := []string{strconv.Itoa(song.ID), song.Song.Group, song.Song.Name, releaseDate(song), song.InfoSong.Link}
+	if w.lyrics {
+		record = append(record, song.InfoSong.Text)
+	}
+	return w.csv.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+// ndjsonSong is a line of an NDJSON export: a song with its info flattened.
+type ndjsonSong struct {
+	ID          int    `json:"id"`
+	Group       string `json:"group"`
+	Song        string `json:"song"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Text        string `json:"text,omitempty"`
+}
+
+type ndjsonWriter struct {
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	lyrics bool
+}
+
+func (w *ndjsonWriter) Write(song postgres.Songs) error {
+	line := ndjsonSong{
+		ID:          song.ID,
+		Group:       song.Song.Group,
+		Song:        song.Song.Name,
+		ReleaseDate: releaseDate(song),
+		Link:        song.InfoSong.Link,
+	}
+	if w.lyrics {
+		line.Text = song.InfoSong.Text
+	}
+	return w.enc.Encode(line)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// m3uWriter writes an extended M3U playlist. An entry needs a location, so songs without
+// a link are left out.
+type m3uWriter struct {
+	buf     *bufio.Writer
+	started bool
+}
+
+func (w *m3uWriter) start() {
+	if !w.started {
+		w.started = true
+		w.buf.WriteString("#EXTM3U\n")
+	}
+}
+
+func (w *m3uWriter) Write(song postgres.Songs) error {
+	w.start()
+
+	link := strings.TrimSpace(song.InfoSong.Link)
+	if link == "" {
+		return nil
+	}
+
+	// Line breaks would end the entry early.
+	title := strings.Join(strings.Fields(song.Song.Group+" - "+song.Song.Name), " ")
+	_, err := fmt.Fprintf(w.buf, "#EXTINF:-1,%s\n%s\n", title, strings.Join(strings.Fields(link), "%20"))
+	return err
+}
+
+func (w *m3uWriter) Close() error {
+	w.start()
+	return w.buf.Flush()
+}
+
+const xspfNamespace = "http://xspf.org/ns/0/"
+
+type xspfTrack struct {
+	XMLName    xml.Name `xml:"track"`
+	Location   string   `xml:"location,omitempty"`
+	Identifier string   `xml:"identifier"`
+	Title      string   `xml:"title"`
+	Creator    string   `xml:"creator"`
+	Annotation string   `xml:"annotation,omitempty"`
+	Meta       []xspfMeta
+}
+
+type xspfMeta struct {
+	XMLName xml.Name `xml:"meta"`
+	Rel     string   `xml:"rel,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+// xspfReleaseDate is the meta rel the release date of a track is written under.
+const xspfReleaseDate = "urn:songLibrary:releaseDate"
+
+// xspfWriter writes an XSPF playlist, with the lyrics of a track as its annotation.
+type xspfWriter struct {
+	buf     *bufio.Writer
+	enc     *xml.Encoder
+	lyrics  bool
+	started bool
+}
+
+var (
+	xspfPlaylist  = xml.StartElement{Name: xml.Name{Local: "playlist"}, Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: "1"}, {Name: xml.Name{Local: "xmlns"}, Value: xspfNamespace}}}
+	xspfTrackList = xml.StartElement{Name: xml.Name{Local: "trackList"}}
+)
+
+func (w *xspfWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	w.buf.WriteString(xml.Header)
+	if err := w.enc.EncodeToken(xspfPlaylist); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeElement("songLibrary", xml.StartElement{Name: xml.Name{Local: "title"}}); err != nil {
+		return err
+	}
+	return w.enc.EncodeToken(xspfTrackList)
+}
+
+func (w *xspfWriter) Write(song postgres.Songs) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	track := xspfTrack{
+		Location:   song.InfoSong.Link,
+		Identifier: "urn:songLibrary:song:" + strconv.Itoa(song.ID),
+		Title:      song.Song.Name,
+		Creator:    song.Song.Group,
+	}
+	if date := releaseDate(song); date != "" {
+		track.Meta = append(track.Meta, xspfMeta{Rel: xspfReleaseDate, Value: date})
+	}
+	if w.lyrics {
+		track.Annotation = song.InfoSong.Text
+	}
+
+	return w.enc.Encode(track)
+}
+
+func (w *xspfWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xspfTrackList.End()); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xspfPlaylist.End()); err != nil {
+		return err
+	}
+	if err := w.enc.Flush(); err != nil {
+		return err
+	}
+	w.buf.WriteString("\n")
+	return w.buf.Flush()
+}
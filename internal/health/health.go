@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses of a readiness report and of each of its checks.
+const (
+	StatusAlive        = "alive"
+	StatusReady        = "ready"
+	StatusNotReady     = "not_ready"
+	StatusShuttingDown = "shutting_down"
+
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check is one dependency the service needs to serve requests.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is how one check went and how long it took.
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of the service with the result of every check. It is ready when
+// every check is up and the service is not shutting down.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready reports whether the service can take requests.
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+// Checker runs the readiness checks. Once Drain was called it reports the service as
+// shutting down, without running them.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// New returns a checker running the checks, each given at most timeout.
+func New(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Drain turns the service not ready for good, so load balancers stop sending it requests
+// before it stops taking them.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check runs every check at once and reports the readiness of the service.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusReady, Checks: make(map[string]Result, len(c.checks))}
+
+	if c.draining.Load() {
+		report.Status = StatusShuttingDown
+		return report
+	}
+
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range c.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check.Run(ctx)
+	res := Result{Status: StatusUp, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+
+	if err != nil {
+		res.Status, res.Error = StatusDown, err.Error()
+	}
+	return res
+}
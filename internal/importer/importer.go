@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"songLibrary/internal/catalog"
+	"songLibrary/internal/config"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcome of importing a row.
+const (
+	StatusAdded        = "added"
+	StatusDuplicate    = "duplicate"
+	StatusNotInCatalog = "not_in_catalog"
+	StatusFailed       = "failed"
+)
+
+type RowResult struct {
+	Row
+	Status string `json:"status"`
+	// ID is the song the row added, or the library song it duplicates.
+	ID    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report lists the result of every row in file order, with totals per status. In a dry run
+// added counts the rows that would be added.
+type Report struct {
+	DryRun       bool        `json:"dryRun"`
+	Total        int         `json:"total"`
+	Added        int         `json:"added"`
+	Duplicate    int         `json:"duplicate"`
+	NotInCatalog int         `json:"notInCatalog"`
+	Failed       int         `json:"failed"`
+	Rows         []RowResult `json:"rows"`
+}
+
+// Importer adds rows to the user library the way AddSong does, looking each song up in
+// the catalog, with at most workers lookups at a time.
+type Importer struct {
+	store   storage.SongStore
+	catalog *catalog.Client
+	workers int
+	maxRows int
+	timeout time.Duration
+	log     *slog.Logger
+}
+
+func New(store storage.SongStore, catalogClient *catalog.Client, cfg config.Import, log *slog.Logger) *Importer {
+	return &Importer{
+		store:   store,
+		catalog: catalogClient,
+		workers: max(cfg.Workers, 1),
+		maxRows: max(cfg.MaxRows, 1),
+		timeout: cfg.Timeout,
+		log:     log,
+	}
+}
+
+// Parse reads the rows of a file in the format, refusing files with too many rows.
+func (im *Importer) Parse(r io.Reader, format string) ([]Row, error) {
+	return Parse(r, format, im.maxRows)
+}
+
+// Run imports rows and reports on each. A dry run checks the library and the catalog but
+// writes nothing. Rows left when ctx is cancelled or the import times out fail.
+func (im *Importer) Run(ctx context.Context, rows []Row, dryRun bool) Report {
+	if im.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, im.timeout)
+		defer cancel()
+	}
+
+	results := make([]RowResult, len(rows))
+
+	// A song repeated in the file is imported once; the repeats are duplicates of the first.
+	first := make(map[string]int, len(rows))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(im.workers, len(rows)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = im.importRow(ctx, rows[i], dryRun)
+			}
+		}()
+	}
+
+	for i, row := range rows {
+		row.Group = strings.TrimSpace(row.Group)
+		row.Song = strings.TrimSpace(row.Song)
+		rows[i] = row
+
+		if row.Group == "" || row.Song == "" {
+			results[i] = RowResult{Row: row, Status: StatusFailed, Error: "group and song are required"}
+			continue
+		}
+
+		key := postgres.ArtistKey(row.Group) + "\x00" + row.Song
+		if j, ok := first[key]; ok {
+			results[i] = RowResult{Row: row, Status: StatusDuplicate, Error: "repeats line " + strconv.Itoa(rows[j].Line)}
+			continue
+		}
+		first[key] = i
+
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := Report{DryRun: dryRun, Total: len(rows), Rows: results}
+	for _, res := range results {
+		switch res.Status {
+		case StatusAdded:
+			report.Added++
+		case StatusDuplicate:
+			report.Duplicate++
+		case StatusNotInCatalog:
+			report.NotInCatalog++
+		default:
+			report.Failed++
+		}
+	}
+
+	return report
+}
+
+func (im *Importer) importRow(ctx context.Context, row Row, dryRun bool) RowResult {
+	res := RowResult{Row: row}
+
+	fail := func(err error) RowResult {
+		res.Status, res.Error = StatusFailed, err.Error()
+		return res
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+
+	// Songs already in the library are not looked up in the catalog again.
+	existing, err := im.store.FindSong(ctx, row.Group, row.Song, im.log)
+	switch {
+	case err == nil:
+		res.Status, res.ID = StatusDuplicate, existing.ID
+		return res
+	case !errors.Is(err, postgres.ErrSongNotFound):
+		return fail(err)
+	}
+
+	info, err := im.catalog.GetInfo(ctx, row.Group, row.Song, im.log)
+	if errors.Is(err, catalog.ErrNotFound) {
+		res.Status = StatusNotInCatalog
+		return res
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if dryRun {
+		res.Status = StatusAdded
+		return res
+	}
+
+	added, err := im.store.AddSong(ctx, postgres.Song{Group: row.Group, Name: row.Song}, info, im.log)
+	if errors.Is(err, postgres.ErrSongExists) {
+		// Added since it was looked up, under this or another name of the artist.
+		res.Status = StatusDuplicate
+		if existing, err := im.store.FindSong(ctx, row.Group, row.Song, im.log); err == nil {
+			res.ID = existing.ID
+		}
+		return res
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	res.Status, res.ID = StatusAdded, added.ID
+	return res
+}
@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Row is a group and song pair to import. Line is where it was read: the line of a CSV
+// or NDJSON file, counting the CSV header, or the 1-based index in a JSON array.
+type Row struct {
+	Line  int    `json:"line"`
+	Group string `json:"group"`
+	Song  string `json:"song"`
+}
+
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return true
+	}
+	return false
+}
+
+// FormatOf guesses the format of a file from its extension, or from a Content-Type.
+func FormatOf(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")); ext {
+	case FormatCSV, FormatJSON, FormatNDJSON:
+		return ext
+	case "jsonl":
+		return FormatNDJSON
+	}
+
+	mediaType, _, _ := strings.Cut(name, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "text/csv":
+		return FormatCSV
+	case "application/json":
+		return FormatJSON
+	case "application/x-ndjson", "application/jsonl":
+		return FormatNDJSON
+	}
+
+	return ""
+}
+
+// Parse reads at most maxRows rows in the format.
+func Parse(r io.Reader, format string, maxRows int) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r, maxRows)
+	case FormatJSON:
+		return parseJSON(r, maxRows)
+	case FormatNDJSON:
+		return parseNDJSON(r, maxRows)
+	}
+	return nil, fmt.Errorf("unknown format %q, use csv, json or ndjson", format)
+}
+
+// parseCSV reads a file whose header names a group and a song column, in any order and
+// among other columns.
+func parseCSV(r io.Reader, maxRows int) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	groupCol, songCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "group":
+			groupCol = i
+		case "song":
+			songCol = i
+		}
+	}
+	if groupCol < 0 || songCol < 0 {
+		return nil, errors.New("csv header must name a group and a song column")
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line}
+		if groupCol < len(record) {
+			row.Group = record[groupCol]
+		}
+		if songCol < len(record) {
+			row.Song = record[songCol]
+		}
+
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseJSON reads an array of {"group": ..., "song": ...} objects.
+func parseJSON(r io.Reader, maxRows int) ([]Row, error) {
+	dec := json.NewDecoder(r)
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.New("json must be an array of objects with group and song")
+	}
+
+	var rows []Row
+	for dec.More() {
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+
+		var row Row
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("json row %d: %w", len(rows)+1, err)
+		}
+		row.Line = len(rows) + 1
+		rows = append(rows, row)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("json: %w", err)
+	}
+
+	return rows, nil
+}
+
+// parseNDJSON reads one {"group": ..., "song": ...} object per line; blank lines are skipped.
+func parseNDJSON(r io.Reader, maxRows int) ([]Row, error) {
+	sc := bufio.NewScanner(r)
+
+	var rows []Row
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("more than %d rows", maxRows)
+		}
+
+		var row Row
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			return nil, fmt.Errorf("ndjson line %d: %w", line, err)
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("ndjson: %w", err)
+	}
+
+	return rows, nil
+}
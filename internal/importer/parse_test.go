@@ -0,0 +1,166 @@
+package importer
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		maxRows int
+		want    []Row
+		wantErr string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			input:  "group,song\nMuse,Uprising\nQueen,Bohemian Rhapsody\n",
+			want:   []Row{{2, "Muse", "Uprising"}, {3, "Queen", "Bohemian Rhapsody"}},
+		},
+		{
+			name:   "csv columns in any order among others",
+			format: FormatCSV,
+			input:  "\ufeffYear, Song ,GROUP\n2009,Uprising,Muse\n1975,\"Bohemian Rhapsody\",Queen\n",
+			want:   []Row{{2, "Muse", "Uprising"}, {3, "Queen", "Bohemian Rhapsody"}},
+		},
+		{
+			name:   "csv short record",
+			format: FormatCSV,
+			input:  "group,song\nMuse\n",
+			want:   []Row{{2, "Muse", ""}},
+		},
+		{
+			name:   "csv header only",
+			format: FormatCSV,
+			input:  "group,song\n",
+		},
+		{
+			name:    "csv empty",
+			format:  FormatCSV,
+			input:   "",
+			wantErr: "empty file",
+		},
+		{
+			name:    "csv without a song column",
+			format:  FormatCSV,
+			input:   "group,title\nMuse,Uprising\n",
+			wantErr: "group and a song column",
+		},
+		{
+			name:    "csv too many rows",
+			format:  FormatCSV,
+			input:   "group,song\na,1\nb,2\nc,3\n",
+			maxRows: 2,
+			wantErr: "more than 2 rows",
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			input:  `[{"group": "Muse", "song": "Uprising"}, {"song": "Bohemian Rhapsody", "group": "Queen", "year": 1975}]`,
+			want:   []Row{{1, "Muse", "Uprising"}, {2, "Queen", "Bohemian Rhapsody"}},
+		},
+		{
+			name:   "json empty array",
+			format: FormatJSON,
+			input:  `[]`,
+		},
+		{
+			name:    "json object instead of an array",
+			format:  FormatJSON,
+			input:   `{"group": "Muse", "song": "Uprising"}`,
+			wantErr: "array",
+		},
+		{
+			name:    "json bad row",
+			format:  FormatJSON,
+			input:   `[{"group": "Muse", "song": "Uprising"}, {"group": 1}]`,
+			wantErr: "json row 2",
+		},
+		{
+			name:    "json unterminated",
+			format:  FormatJSON,
+			input:   `[{"group": "Muse", "song": "Uprising"}`,
+			wantErr: "json",
+		},
+		{
+			name:    "json too many rows",
+			format:  FormatJSON,
+			input:   `[{"group": "a"}, {"group": "b"}]`,
+			maxRows: 1,
+			wantErr: "more than 1 rows",
+		},
+		{
+			name:   "ndjson skips blank lines",
+			format: FormatNDJSON,
+			input:  "{\"group\": \"Muse\", \"song\": \"Uprising\"}\n\n  \n{\"group\": \"Queen\", \"song\": \"Bohemian Rhapsody\"}\n",
+			want:   []Row{{1, "Muse", "Uprising"}, {4, "Queen", "Bohemian Rhapsody"}},
+		},
+		{
+			name:    "ndjson bad line",
+			format:  FormatNDJSON,
+			input:   "{\"group\": \"Muse\", \"song\": \"Uprising\"}\nnot json\n",
+			wantErr: "ndjson line 2",
+		},
+		{
+			name:    "ndjson too many rows",
+			format:  FormatNDJSON,
+			input:   "{\"group\": \"a\"}\n{\"group\": \"b\"}\n",
+			maxRows: 1,
+			wantErr: "more than 1 rows",
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			input:   "<songs/>",
+			wantErr: "unknown format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxRows := tt.maxRows
+			if maxRows == 0 {
+				maxRows = 100
+			}
+
+			got, err := Parse(strings.NewReader(tt.input), tt.format, maxRows)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"songs.csv":                FormatCSV,
+		"SONGS.JSON":               FormatJSON,
+		"songs.ndjson":             FormatNDJSON,
+		"songs.jsonl":              FormatNDJSON,
+		"text/csv; charset=utf-8":  FormatCSV,
+		"application/json":         FormatJSON,
+		"application/x-ndjson":     FormatNDJSON,
+		"songs.txt":                "",
+		"application/octet-stream": "",
+		"":                         "",
+	}
+
+	for name, want := range tests {
+		if got := FormatOf(name); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
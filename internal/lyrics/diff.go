@@ -0,0 +1,180 @@
+package lyrics
+
+import "errors"
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// MaxDiffLines is the most lines lyrics may have to be compared. Diff takes time
+// proportional to the product of both line counts.
+const MaxDiffLines = 5000
+
+var ErrTooManyLines = errors.New("lyrics have too many lines to compare")
+
+// DiffLine is a line of a lyric diff. Old and New are its numbers in the old and the new
+// lyrics, the same Split gives them; a line only one side has is 0 on the other.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Old  int    `json:"old,omitempty"`
+	New  int    `json:"new,omitempty"`
+	Text string `json:"text"`
+}
+
+// Diff compares the lines Split reads from two lyrics and returns the shortest edit turning
+// the old into the new one, with deleted lines before the lines inserted in their place.
+// Blank lines only separate verses and are not compared. Lyrics with more than
+// MaxDiffLines lines fail with ErrTooManyLines.
+func Diff(before, after string) ([]DiffLine, error) {
+	a, b := Lines(Split(before)), Lines(Split(after))
+	if len(a) > MaxDiffLines || len(b) > MaxDiffLines {
+		return nil, ErrTooManyLines
+	}
+
+	// Lines are compared by number, so every comparison costs the same.
+	ids := make(map[string]int)
+	intern := func(lines []Line) []int {
+		out := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line.Text]
+			if !ok {
+				id = len(ids)
+				ids[line.Text] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+
+	d := differ{
+		a:        intern(a),
+		b:        intern(b),
+		deleted:  make([]bool, len(a)),
+		inserted: make([]bool, len(b)),
+		fwd:      make([]int, len(b)+1),
+		bwd:      make([]int, len(b)+1),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	diff := make([]DiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.deleted[i]:
+			diff = append(diff, DiffLine{Op: DiffDelete, Old: a[i].Number, Text: a[i].Text})
+			i++
+		case j < len(b) && d.inserted[j]:
+			diff = append(diff, DiffLine{Op: DiffInsert, New: b[j].Number, Text: b[j].Text})
+			j++
+		default:
+			diff = append(diff, DiffLine{Op: DiffEqual, Old: a[i].Number, New: b[j].Number, Text: a[i].Text})
+			i++
+			j++
+		}
+	}
+
+	return diff, nil
+}
+
+// differ finds the longest common subsequence of two line lists with Hirschberg's
+// algorithm, which needs two rows of lengths instead of the whole table, and marks the
+// lines that are not part of it.
+type differ struct {
+	a, b              []int
+	deleted, inserted []bool
+	fwd, bwd          []int
+}
+
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	// Common lines at both ends are not part of the edit.
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	case aHi-aLo == 1:
+		// The line is kept if the other side has it; everything else there is inserted.
+		kept := -1
+		for j := bLo; j < bHi; j++ {
+			if d.b[j] == d.a[aLo] {
+				kept = j
+				break
+			}
+		}
+		d.deleted[aLo] = kept < 0
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = j != kept
+		}
+	default:
+		// Split the old lines in half and the new ones where the common subsequences of
+		// both halves are longest together.
+		mid := (aLo + aHi) / 2
+		d.forward(aLo, mid, bLo, bHi)
+		d.backward(mid, aHi, bLo, bHi)
+
+		split, best := bLo, -1
+		for j := 0; j <= bHi-bLo; j++ {
+			if n := d.fwd[j] + d.bwd[j]; n > best {
+				split, best = bLo+j, n
+			}
+		}
+
+		d.compare(aLo, mid, bLo, split)
+		d.compare(mid, aHi, split, bHi)
+	}
+}
+
+// forward sets fwd[j] to the length of the longest common subsequence of a[aLo:aHi] and
+// b[bLo:bLo+j].
+func (d *differ) forward(aLo, aHi, bLo, bHi int) {
+	row := d.fwd[:bHi-bLo+1]
+	clear(row)
+
+	for i := aLo; i < aHi; i++ {
+		diag := 0
+		for j := 1; j < len(row); j++ {
+			up := row[j]
+			if d.a[i] == d.b[bLo+j-1] {
+				row[j] = diag + 1
+			} else {
+				row[j] = max(row[j], row[j-1])
+			}
+			diag = up
+		}
+	}
+}
+
+// backward sets bwd[j] to the length of the longest common subsequence of a[aLo:aHi] and
+// b[bLo+j:bHi].
+func (d *differ) backward(aLo, aHi, bLo, bHi int) {
+	row := d.bwd[:bHi-bLo+1]
+	clear(row)
+
+	for i := aHi - 1; i >= aLo; i-- {
+		diag := 0
+		for j := len(row) - 2; j >= 0; j-- {
+			down := row[j]
+			if d.a[i] == d.b[bLo+j] {
+				row[j] = diag + 1
+			} else {
+				row[j] = max(row[j], row[j+1])
+			}
+			diag = down
+		}
+	}
+}
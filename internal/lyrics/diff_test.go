@@ -0,0 +1,166 @@
+package lyrics
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []DiffLine
+	}{
+		{
+			name: "both empty",
+			want: []DiffLine{},
+		},
+		{
+			name:  "added lyrics",
+			after: "a\nb",
+			want: []DiffLine{
+				{Op: DiffInsert, New: 1, Text: "a"},
+				{Op: DiffInsert, New: 2, Text: "b"},
+			},
+		},
+		{
+			name:   "removed lyrics",
+			before: "a",
+			want:   []DiffLine{{Op: DiffDelete, Old: 1, Text: "a"}},
+		},
+		{
+			name:   "unchanged apart from spaces and verse breaks",
+			before: "a\n  b\n\nc",
+			after:  "a\nb\t\n\n\n\nc\r\n",
+			want: []DiffLine{
+				{Op: DiffEqual, Old: 1, New: 1, Text: "a"},
+				{Op: DiffEqual, Old: 2, New: 2, Text: "b"},
+				{Op: DiffEqual, Old: 3, New: 3, Text: "c"},
+			},
+		},
+		{
+			name:   "changed line deleted before inserted",
+			before: "a\nb\nc",
+			after:  "a\nB\nc",
+			want: []DiffLine{
+				{Op: DiffEqual, Old: 1, New: 1, Text: "a"},
+				{Op: DiffDelete, Old: 2, Text: "b"},
+				{Op: DiffInsert, New: 2, Text: "B"},
+				{Op: DiffEqual, Old: 3, New: 3, Text: "c"},
+			},
+		},
+		{
+			name:   "numbers skip blank lines",
+			before: "a\n\nb\n\nc",
+			after:  "a\n\nx\nb\n\nc",
+			want: []DiffLine{
+				{Op: DiffEqual, Old: 1, New: 1, Text: "a"},
+				{Op: DiffInsert, New: 2, Text: "x"},
+				{Op: DiffEqual, Old: 2, New: 3, Text: "b"},
+				{Op: DiffEqual, Old: 3, New: 4, Text: "c"},
+			},
+		},
+		{
+			name:   "moved line",
+			before: "a\nb\nc\nd",
+			after:  "b\nc\nd\na",
+			want: []DiffLine{
+				{Op: DiffDelete, Old: 1, Text: "a"},
+				{Op: DiffEqual, Old: 2, New: 1, Text: "b"},
+				{Op: DiffEqual, Old: 3, New: 2, Text: "c"},
+				{Op: DiffEqual, Old: 4, New: 3, Text: "d"},
+				{Op: DiffInsert, New: 4, Text: "a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("Diff() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff(%q, %q) =\n%+v\nwant\n%+v", tt.before, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffShortest checks on random lyrics that the diff turns the old lines into the new
+// ones and keeps as many lines as the longest common subsequence has.
+func TestDiffShortest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+
+	for range 500 {
+		a, b := random(), random()
+
+		diff, err := Diff(strings.Join(a, "\n"), strings.Join(b, "\n"))
+		if err != nil {
+			t.Fatalf("Diff() error: %v", err)
+		}
+
+		var old, new []string
+		equal := 0
+		for _, line := range diff {
+			if line.Op != DiffInsert {
+				old = append(old, line.Text)
+			}
+			if line.Op != DiffDelete {
+				new = append(new, line.Text)
+			}
+			if line.Op == DiffEqual {
+				equal++
+			}
+		}
+
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("Diff(%q, %q) = %+v does not rebuild both sides", a, b, diff)
+		}
+		if want := lcsLength(a, b); equal != want {
+			t.Fatalf("Diff(%q, %q) keeps %d lines, want %d", a, b, equal, want)
+		}
+	}
+}
+
+func lcsLength(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func TestDiffTooManyLines(t *testing.T) {
+	long := strings.Repeat("la\n", MaxDiffLines+1)
+
+	if _, err := Diff(long, "la"); !errors.Is(err, ErrTooManyLines) {
+		t.Errorf("Diff() of a long old text error = %v, want %v", err, ErrTooManyLines)
+	}
+	if _, err := Diff("la", long); !errors.Is(err, ErrTooManyLines) {
+		t.Errorf("Diff() of a long new text error = %v, want %v", err, ErrTooManyLines)
+	}
+	if _, err := Diff(strings.Repeat("la\n", MaxDiffLines), "la"); err != nil {
+		t.Errorf("Diff() at the limit error: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package lyrics
+
+import (
+	"errors"
+	"strings"
+)
+
+type Line struct {
+	Number int    `json:"number"`
+	Verse  int    `json:"verse"`
+	Text   string `json:"text"`
+}
+
+type Verse struct {
+	Number int    `json:"number"`
+	Lines  []Line `json:"lines"`
+}
+
+type VersePage struct {
+	Verses      []Verse `json:"verses"`
+	Page        int     `json:"page"`
+	PerPage     int     `json:"per_page"`
+	TotalVerses int     `json:"total_verses"`
+	TotalLines  int     `json:"total_lines"`
+}
+
+type LinePage struct {
+	Lines       []Line `json:"lines"`
+	Page        int    `json:"page"`
+	PerPage     int    `json:"per_page"`
+	TotalVerses int    `json:"total_verses"`
+	TotalLines  int    `json:"total_lines"`
+}
+
+var ErrPageOutOfRange = errors.New("page is out of range")
+
+// Split breaks lyrics into verses separated by blank lines. Leading and trailing spaces and
+// tabs are dropped from every line, since the seeded lyrics mix both as indentation.
+// Lines are numbered across the whole song, starting from 1.
+func Split(text string) []Verse {
+	var (
+		verses []Verse
+		number int
+	)
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			if len(verses) > 0 && len(verses[len(verses)-1].Lines) > 0 {
+				verses = append(verses, Verse{Number: len(verses) + 1})
+			}
+			continue
+		}
+
+		if len(verses) == 0 {
+			verses = append(verses, Verse{Number: 1})
+		}
+
+		number++
+		last := &verses[len(verses)-1]
+		last.Lines = append(last.Lines, Line{Number: number, Verse: last.Number, Text: line})
+	}
+
+	if len(verses) > 0 && len(verses[len(verses)-1].Lines) == 0 {
+		verses = verses[:len(verses)-1]
+	}
+
+	return verses
+}
+
+// Lines flattens verses back into their numbered lines.
+func Lines(verses []Verse) []Line {
+	var lines []Line
+	for _, v := range verses {
+		lines = append(lines, v.Lines...)
+	}
+	return lines
+}
+
+// Verses returns one page of verses. A perPage of 0 puts every verse on a single page.
+func Verses(text string, page, perPage int) (VersePage, error) {
+	verses := Split(text)
+	totalLines := len(Lines(verses))
+
+	from, to, perPage, err := bounds(len(verses), page, perPage)
+	if err != nil {
+		return VersePage{}, err
+	}
+
+	return VersePage{
+		Verses:      verses[from:to],
+		Page:        page,
+		PerPage:     perPage,
+		TotalVerses: len(verses),
+		TotalLines:  totalLines,
+	}, nil
+}
+
+// NumberedLines returns one page of numbered lines. A perPage of 0 puts every line on a single page.
+func NumberedLines(text string, page, perPage int) (LinePage, error) {
+	verses := Split(text)
+	lines := Lines(verses)
+
+	from, to, perPage, err := bounds(len(lines), page, perPage)
+	if err != nil {
+		return LinePage{}, err
+	}
+
+	return LinePage{
+		Lines:       lines[from:to],
+		Page:        page,
+		PerPage:     perPage,
+		TotalVerses: len(verses),
+		TotalLines:  len(lines),
+	}, nil
+}
+
+// bounds converts a 1-based page into slice bounds. The first page always exists, even
+// when there is nothing on it.
+func bounds(total, page, perPage int) (int, int, int, error) {
+	if perPage == 0 {
+		perPage = max(total, 1)
+	}
+
+	from := (page - 1) * perPage
+	if from > 0 && from >= total {
+		return 0, 0, 0, ErrPageOutOfRange
+	}
+
+	return from, min(from+perPage, total), perPage, nil
+}
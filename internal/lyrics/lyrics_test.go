@@ -0,0 +1,118 @@
+package lyrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Verse
+	}{
+		{
+			name: "empty",
+			text: "",
+			want: nil,
+		},
+		{
+			name: "only blank lines",
+			text: "\n \t\n\n",
+			want: nil,
+		},
+		{
+			name: "one verse",
+			text: "Ooh baby\nDon't you know",
+			want: []Verse{
+				{Number: 1, Lines: []Line{{1, 1, "Ooh baby"}, {2, 1, "Don't you know"}}},
+			},
+		},
+		{
+			name: "verses numbered across the song",
+			text: "a\nb\n\nc\n\n\n\td  \n",
+			want: []Verse{
+				{Number: 1, Lines: []Line{{1, 1, "a"}, {2, 1, "b"}}},
+				{Number: 2, Lines: []Line{{3, 2, "c"}}},
+				{Number: 3, Lines: []Line{{4, 3, "d"}}},
+			},
+		},
+		{
+			name: "leading blank lines and CRLF",
+			text: "\r\n\r\n  a\r\nb\r\n \r\nc",
+			want: []Verse{
+				{Number: 1, Lines: []Line{{1, 1, "a"}, {2, 1, "b"}}},
+				{Number: 2, Lines: []Line{{3, 2, "c"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersesAndLinesPaging(t *testing.T) {
+	const text = "a\nb\n\nc\n\nd\ne"
+
+	tests := []struct {
+		name       string
+		page       int
+		perPage    int
+		wantVerses []int
+		wantLines  []int
+		wantErr    error
+	}{
+		{name: "everything on one page", page: 1, perPage: 0, wantVerses: []int{1, 2, 3}, wantLines: []int{1, 2, 3, 4, 5}},
+		{name: "first page", page: 1, perPage: 2, wantVerses: []int{1, 2}, wantLines: []int{1, 2}},
+		{name: "last partial page", page: 2, perPage: 2, wantVerses: []int{3}, wantLines: []int{3, 4}},
+		{name: "past the end", page: 4, perPage: 2, wantErr: ErrPageOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vp, err := Verses(text, tt.page, tt.perPage)
+			lp, lerr := NumberedLines(text, tt.page, tt.perPage)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !errors.Is(lerr, tt.wantErr) {
+					t.Fatalf("errors = %v, %v, want %v", err, lerr, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || lerr != nil {
+				t.Fatalf("errors = %v, %v", err, lerr)
+			}
+
+			var verses, lines []int
+			for _, v := range vp.Verses {
+				verses = append(verses, v.Number)
+			}
+			for _, l := range lp.Lines {
+				lines = append(lines, l.Number)
+			}
+			if !reflect.DeepEqual(verses, tt.wantVerses) {
+				t.Errorf("verses = %v, want %v", verses, tt.wantVerses)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines = %v, want %v", lines, tt.wantLines)
+			}
+			if vp.TotalVerses != 3 || vp.TotalLines != 5 || lp.TotalVerses != 3 || lp.TotalLines != 5 {
+				t.Errorf("totals = %d/%d verses, %d/%d lines, want 3 and 5", vp.TotalVerses, lp.TotalVerses, vp.TotalLines, lp.TotalLines)
+			}
+		})
+	}
+}
+
+func TestPagingEmptyLyrics(t *testing.T) {
+	if _, err := NumberedLines("", 1, 10); err != nil {
+		t.Errorf("first page of empty lyrics: %v", err)
+	}
+	if _, err := NumberedLines("", 2, 10); !errors.Is(err, ErrPageOutOfRange) {
+		t.Errorf("second page of empty lyrics: error = %v, want %v", err, ErrPageOutOfRange)
+	}
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log/slog"
+	"net/http"
+	"songLibrary/internal/storage"
+	"strconv"
+	"time"
+)
+
+const namespace = "songlibrary"
+
+// Outcomes of a catalog lookup.
+const (
+	CatalogFound       = "found"
+	CatalogNotFound    = "not_found"
+	CatalogUnavailable = "unavailable"
+	CatalogMalformed   = "malformed"
+	CatalogCanceled    = "canceled"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to answer HTTP requests by method, route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	catalogDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "catalog_lookup_duration_seconds",
+		Help:      "Time catalog lookups took, retries included, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	catalogErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "catalog_lookup_errors_total",
+		Help:      "Catalog lookups that failed, by outcome. A song the catalog does not have is not a failure.",
+	}, []string{"outcome"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware counts requests and times them by the route pattern they matched, so paths
+// with ids or query strings do not each get their own series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"method": r.Method, "route": route, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveCatalog records a catalog lookup with its outcome.
+func ObserveCatalog(outcome string, duration time.Duration) {
+	catalogDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+	if outcome != CatalogFound && outcome != CatalogNotFound {
+		catalogErrors.WithLabelValues(outcome).Inc()
+	}
+}
+
+// WatchDB exposes the connection pool statistics of db: open, in-use and idle connections
+// and how often and how long callers waited for one.
+func WatchDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// WatchLibrary exposes how many songs the library, the trash and the global catalog hold,
+// counted by store at every scrape.
+func WatchLibrary(store storage.CountStore, log *slog.Logger) {
+	prometheus.MustRegister(&libraryCollector{store: store, log: log})
+}
+
+var (
+	librarySongsDesc = prometheus.NewDesc(namespace+"_library_songs", "Songs in the user library.", nil, nil)
+	trashSongsDesc   = prometheus.NewDesc(namespace+"_trash_songs", "Songs in the trash.", nil, nil)
+	catalogSongsDesc = prometheus.NewDesc(namespace+"_catalog_songs", "Songs in the global Library catalog.", nil, nil)
+)
+
+type libraryCollector struct {
+	store storage.CountStore
+	log   *slog.Logger
+}
+
+func (c *libraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- librarySongsDesc
+	ch <- trashSongsDesc
+	ch <- catalogSongsDesc
+}
+
+// Collect leaves the gauges out of a scrape when counting fails, rather than failing the
+// scrape and losing the other metrics with them.
+func (c *libraryCollector) Collect(ch chan<- prometheus.Metric) {
+	const op = "internal.metrics.Collect()"
+
+	counts, err := c.store.CountSongs(context.Background(), c.log)
+	if err != nil {
+		c.log.Error("Error counting songs", "error", err, "operation", op)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(librarySongsDesc, prometheus.GaugeValue, float64(counts.Library))
+	ch <- prometheus.MustNewConstMetric(trashSongsDesc, prometheus.GaugeValue, float64(counts.Trash))
+	ch <- prometheus.MustNewConstMetric(catalogSongsDesc, prometheus.GaugeValue, float64(counts.Catalog))
+}
@@ -0,0 +1,134 @@
+package plays
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"songLibrary/internal/config"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+// ErrQueueFull means plays arrive faster than they are written; the play was not queued.
+var ErrQueueFull = errors.New("play queue is full")
+
+// Recorder queues plays and writes them to the storage in batches, so a play costs the
+// request no database round trip.
+type Recorder struct {
+	store     storage.PlayStore
+	queue     chan postgres.Play
+	batchSize int
+	interval  time.Duration
+	log       *slog.Logger
+}
+
+func NewRecorder(store storage.PlayStore, cfg config.Plays, log *slog.Logger) *Recorder {
+	return &Recorder{
+		store:     store,
+		queue:     make(chan postgres.Play, max(cfg.QueueSize, 1)),
+		batchSize: max(cfg.BatchSize, 1),
+		interval:  cmp.Or(cfg.FlushInterval, time.Second),
+		log:       log,
+	}
+}
+
+// Record queues a play without waiting for it to be written.
+func (r *Recorder) Record(p postgres.Play) error {
+	select {
+	case r.queue <- p:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run writes queued plays whenever a batch is full or the flush interval passes. Once ctx
+// is cancelled it writes the plays still queued and returns, so it must be stopped after
+// the server stopped taking requests and before the storage is closed.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := make([]postgres.Play, 0, r.batchSize)
+
+	add := func(p postgres.Play) {
+		batch = append(batch, p)
+		if len(batch) == r.batchSize {
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case p := <-r.queue:
+			add(p)
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		case <-ctx.Done():
+			for {
+				select {
+				case p := <-r.queue:
+					add(p)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []postgres.Play) {
+	const op = "internal.plays.flush()"
+
+	if len(batch) == 0 {
+		return
+	}
+
+	// Plays outlive the requests that recorded them and are still written at shutdown.
+	ctx := context.Background()
+
+	failed := 0
+	n, err := r.store.AddPlays(ctx, batch, r.log)
+	if err != nil && len(batch) > 1 {
+		// One bad play fails the whole batch, so write the plays one at a time and drop
+		// only those that cannot be written.
+		r.log.Warn("Error writing plays, writing them one by one", "error", err, "plays", len(batch), "operation", op)
+		n, failed, err = r.flushEach(ctx, batch)
+	} else if err != nil {
+		failed = len(batch)
+	}
+
+	if failed > 0 {
+		r.log.Error("Error writing plays, dropping them", "error", err, "plays", failed, "operation", op)
+	}
+	if missing := len(batch) - n - failed; missing > 0 {
+		r.log.Warn("Dropped plays of songs not in the library", "plays", missing, "operation", op)
+	}
+	r.log.Debug("plays written", "plays", n)
+}
+
+// flushEach writes the plays one by one and reports how many were written, how many failed
+// and the last error.
+func (r *Recorder) flushEach(ctx context.Context, batch []postgres.Play) (int, int, error) {
+	var (
+		written, failed int
+		lastErr         error
+	)
+
+	for _, p := range batch {
+		n, err := r.store.AddPlays(ctx, []postgres.Play{p}, r.log)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		written += n
+	}
+
+	return written, failed, lastErr
+}
@@ -0,0 +1,125 @@
+package plays
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/config"
+	"songLibrary/internal/storage"
+	"songLibrary/internal/storage/postgres"
+	"testing"
+	"time"
+)
+
+// fakeStore writes plays of songs 1 to 100 and fails every batch holding a play of song 13.
+type fakeStore struct {
+	storage.PlayStore
+
+	calls   [][]int
+	written []int
+}
+
+func (f *fakeStore) AddPlays(ctx context.Context, plays []postgres.Play, log *slog.Logger) (int, error) {
+	var ids []int
+	for _, p := range plays {
+		ids = append(ids, p.SongID)
+	}
+	f.calls = append(f.calls, ids)
+
+	if slices.Contains(ids, 13) {
+		return 0, errors.New("value out of range")
+	}
+
+	n := 0
+	for _, id := range ids {
+		if id <= 100 {
+			f.written = append(f.written, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name        string
+		batchSize   int
+		songs       []int
+		wantCalls   [][]int
+		wantWritten []int
+	}{
+		{
+			name:      "nothing queued",
+			batchSize: 2,
+		},
+		{
+			name:        "full batches and the rest at shutdown",
+			batchSize:   2,
+			songs:       []int{1, 2, 3, 4, 5},
+			wantCalls:   [][]int{{1, 2}, {3, 4}, {5}},
+			wantWritten: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:        "plays of missing songs are dropped",
+			batchSize:   10,
+			songs:       []int{1, 200, 3},
+			wantCalls:   [][]int{{1, 200, 3}},
+			wantWritten: []int{1, 3},
+		},
+		{
+			name:        "a failed batch is written play by play",
+			batchSize:   3,
+			songs:       []int{1, 13, 3, 4},
+			wantCalls:   [][]int{{1, 13, 3}, {1}, {13}, {3}, {4}},
+			wantWritten: []int{1, 3, 4},
+		},
+		{
+			name:      "a failed single play is dropped",
+			batchSize: 1,
+			songs:     []int{13},
+			wantCalls: [][]int{{13}},
+		},
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			recorder := NewRecorder(store, config.Plays{BatchSize: tt.batchSize, FlushInterval: time.Hour, QueueSize: 10}, log)
+
+			for _, id := range tt.songs {
+				if err := recorder.Record(postgres.Play{SongID: id, PlayedAt: time.Now()}); err != nil {
+					t.Fatalf("Record(%d) error: %v", id, err)
+				}
+			}
+
+			// A cancelled context makes Run write everything queued and return.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			recorder.Run(ctx)
+
+			if !slices.EqualFunc(store.calls, tt.wantCalls, slices.Equal) {
+				t.Errorf("AddPlays calls = %v, want %v", store.calls, tt.wantCalls)
+			}
+			if !slices.Equal(store.written, tt.wantWritten) {
+				t.Errorf("written = %v, want %v", store.written, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestRecordQueueFull(t *testing.T) {
+	recorder := NewRecorder(&fakeStore{}, config.Plays{BatchSize: 1, QueueSize: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i := range 2 {
+		if err := recorder.Record(postgres.Play{SongID: i + 1}); err != nil {
+			t.Fatalf("Record() error: %v", err)
+		}
+	}
+	if err := recorder.Record(postgres.Play{SongID: 3}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Record() on a full queue error = %v, want %v", err, ErrQueueFull)
+	}
+}
@@ -21,7 +21,7 @@ func Connection(log *slog.Logger) *sql.DB {
 	db, err := sql.Open("postgres", psqlInfo)
 
 	if err != nil {
-		log.Error("Error to connect database", err, op)
+		log.Error("Error to connect database", "error", err, "operation", op)
 	}
 
 	return db
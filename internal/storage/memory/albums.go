@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+type album struct {
+	id          int
+	artistID    int
+	title       string
+	releaseDate *time.Time
+	albumType   string
+	tracks      []track
+}
+
+type track struct {
+	number int
+	songID int
+}
+
+func (s *Storage) CreateAlbum(ctx context.Context, al postgres.NewAlbum, log *slog.Logger) (postgres.AlbumDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.resolveArtist(al.Artist)
+
+	for _, existing := range s.albums {
+		if existing.artistID == a.id && existing.title == al.Title {
+			return postgres.AlbumDetails{}, postgres.ErrAlbumExists
+		}
+	}
+
+	tracks, err := s.tracks(al.Tracks)
+	if err != nil {
+		return postgres.AlbumDetails{}, err
+	}
+
+	s.nextAlbumID++
+	created := &album{
+		id:          s.nextAlbumID,
+		artistID:    a.id,
+		title:       al.Title,
+		releaseDate: al.ReleaseDate,
+		albumType:   al.Type,
+		tracks:      tracks,
+	}
+	s.albums = append(s.albums, created)
+
+	return s.albumDetails(created), nil
+}
+
+func (s *Storage) ListAlbums(ctx context.Context, q postgres.AlbumQuery, log *slog.Logger) (postgres.AlbumPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []*album
+	for _, al := range s.albums {
+		if (q.ArtistID == 0 || al.artistID == q.ArtistID) && (q.Type == "" || al.albumType == q.Type) {
+			matched = append(matched, al)
+		}
+	}
+
+	// Newest first, albums without a release date last, like the SQL ORDER BY.
+	slices.SortFunc(matched, func(a, b *album) int {
+		switch {
+		case a.releaseDate == nil && b.releaseDate != nil:
+			return 1
+		case a.releaseDate != nil && b.releaseDate == nil:
+			return -1
+		case a.releaseDate != nil && !a.releaseDate.Equal(*b.releaseDate):
+			return b.releaseDate.Compare(*a.releaseDate)
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+
+	p := postgres.AlbumPage{Items: []postgres.Album{}, Total: len(matched), Limit: q.Limit, Offset: q.Offset}
+
+	if q.Offset < len(matched) {
+		matched = matched[q.Offset:]
+		if len(matched) > q.Limit {
+			matched = matched[:q.Limit]
+		}
+		for _, al := range matched {
+			p.Items = append(p.Items, s.albumView(al))
+		}
+	}
+
+	return p, nil
+}
+
+func (s *Storage) GetAlbum(ctx context.Context, id int, log *slog.Logger) (postgres.AlbumDetails, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	al := s.findAlbum(id)
+	if al == nil {
+		return postgres.AlbumDetails{}, postgres.ErrAlbumNotFound
+	}
+
+	return s.albumDetails(al), nil
+}
+
+func (s *Storage) SetAlbumTracks(ctx context.Context, id int, songIDs []int, log *slog.Logger) (postgres.AlbumDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	al := s.findAlbum(id)
+	if al == nil {
+		return postgres.AlbumDetails{}, postgres.ErrAlbumNotFound
+	}
+
+	tracks, err := s.tracks(songIDs)
+	if err != nil {
+		return postgres.AlbumDetails{}, err
+	}
+	al.tracks = tracks
+
+	return s.albumDetails(al), nil
+}
+
+func (s *Storage) DeleteAlbum(ctx context.Context, id int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, al := range s.albums {
+		if al.id == id {
+			s.albums = append(s.albums[:i], s.albums[i+1:]...)
+			return nil
+		}
+	}
+
+	return postgres.ErrAlbumNotFound
+}
+
+func (s *Storage) tracks(songIDs []int) ([]track, error) {
+	tracks := make([]track, 0, len(songIDs))
+	for i, songID := range songIDs {
+		if s.find(songID) == nil {
+			return nil, postgres.ErrSongNotFound
+		}
+		tracks = append(tracks, track{number: i + 1, songID: songID})
+	}
+	return tracks, nil
+}
+
+func (s *Storage) albumView(al *album) postgres.Album {
+	return postgres.Album{
+		ID:          al.id,
+		Title:       al.title,
+		ArtistID:    al.artistID,
+		Artist:      s.artist(al.artistID).name,
+		ReleaseDate: al.releaseDate,
+		Type:        al.albumType,
+		TrackCount:  len(s.albumTracks(al)),
+	}
+}
+
+func (s *Storage) albumDetails(al *album) postgres.AlbumDetails {
+	details := postgres.AlbumDetails{Album: s.albumView(al), Tracks: []postgres.Track{}}
+	for _, t := range s.albumTracks(al) {
+		details.Tracks = append(details.Tracks, postgres.Track{Number: t.number, Songs: s.view(s.find(t.songID))})
+	}
+	return details
+}
+
+// albumTracks returns the tracks of an album whose songs are in the library, not the trash.
+func (s *Storage) albumTracks(al *album) []track {
+	tracks := make([]track, 0, len(al.tracks))
+	for _, t := range al.tracks {
+		if s.find(t.songID) != nil {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
+func (s *Storage) findAlbum(id int) *album {
+	for _, al := range s.albums {
+		if al.id == id {
+			return al
+		}
+	}
+	return nil
+}
+
+// albumReleaseDate is the release date of the earliest album a library song is on.
+func (s *Storage) albumReleaseDate(songID int) *time.Time {
+	var earliest *time.Time
+	for _, al := range s.albums {
+		if al.releaseDate == nil || (earliest != nil && !al.releaseDate.Before(*earliest)) {
+			continue
+		}
+		if slices.ContainsFunc(al.tracks, func(t track) bool { return t.songID == songID }) {
+			earliest = al.releaseDate
+		}
+	}
+	return earliest
+}
+
+// dropFromAlbums removes a purged song from the track listings, as the foreign key cascade
+// does in PostgreSQL. The other tracks keep their numbers.
+func (s *Storage) dropFromAlbums(songID int) {
+	for _, al := range s.albums {
+		al.tracks = slices.DeleteFunc(al.tracks, func(t track) bool { return t.songID == songID })
+	}
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"strings"
+)
+
+// knownAliases mirrors the artists the 0006 schema migration creates for misspellings in the seed.
+var knownAliases = map[string][]string{
+	"Future": {"Furure"},
+}
+
+type artist struct {
+	id       int
+	name     string
+	sortName string
+	aliases  []string
+}
+
+func (s *Storage) ListArtists(ctx context.Context, log *slog.Logger) ([]postgres.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	artists := make([]postgres.Artist, 0, len(s.artists))
+	for _, a := range s.artists {
+		artists = append(artists, s.artistView(a))
+	}
+
+	slices.SortFunc(artists, func(a, b postgres.Artist) int {
+		if c := strings.Compare(a.SortName, b.SortName); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return artists, nil
+}
+
+func (s *Storage) GetArtist(ctx context.Context, id int, log *slog.Logger) (postgres.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a := s.artist(id)
+	if a == nil {
+		return postgres.Artist{}, postgres.ErrArtistNotFound
+	}
+
+	return s.artistView(a), nil
+}
+
+func (s *Storage) RenameArtist(ctx context.Context, id int, name, sortName string, log *slog.Logger) (postgres.Artist, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.artist(id)
+	if a == nil {
+		return postgres.Artist{}, postgres.ErrArtistNotFound
+	}
+
+	name = postgres.CleanArtistName(name)
+	if sortName == "" {
+		sortName = postgres.SortName(name)
+	}
+
+	if owner := s.findArtist(name); owner != nil && owner != a {
+		return postgres.Artist{}, postgres.ErrArtistExists
+	}
+
+	key := postgres.ArtistKey(name)
+	a.aliases = slices.DeleteFunc(a.aliases, func(alias string) bool { return postgres.ArtistKey(alias) == key })
+	if postgres.ArtistKey(a.name) != key {
+		a.aliases = append(a.aliases, a.name)
+	}
+	a.name, a.sortName = name, sortName
+
+	// The group of every song of the artist changes with its name.
+	for _, sg := range s.songs {
+		if sg.artistID == a.id {
+			sg.version++
+		}
+	}
+	for _, sg := range s.trash {
+		if sg.artistID == a.id {
+			sg.version++
+		}
+	}
+
+	return s.artistView(a), nil
+}
+
+func (s *Storage) artistView(a *artist) postgres.Artist {
+	aliases := slices.Clone(a.aliases)
+	if aliases == nil {
+		aliases = []string{}
+	}
+	slices.Sort(aliases)
+
+	count := 0
+	for _, sg := range s.songs {
+		if sg.artistID == a.id {
+			count++
+		}
+	}
+
+	return postgres.Artist{ID: a.id, Name: a.name, SortName: a.sortName, Aliases: aliases, SongCount: count}
+}
+
+func (s *Storage) artist(id int) *artist {
+	for _, a := range s.artists {
+		if a.id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// findArtist looks an artist up by name or alias, compared like find_artist() in SQL.
+func (s *Storage) findArtist(name string) *artist {
+	key := postgres.ArtistKey(name)
+	for _, a := range s.artists {
+		if postgres.ArtistKey(a.name) == key {
+			return a
+		}
+	}
+	for _, a := range s.artists {
+		for _, alias := range a.aliases {
+			if postgres.ArtistKey(alias) == key {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// resolveArtist finds an artist by name or alias and creates it if there is none.
+func (s *Storage) resolveArtist(name string) *artist {
+	if a := s.findArtist(name); a != nil {
+		return a
+	}
+
+	s.nextArtistID++
+	a := &artist{id: s.nextArtistID, name: postgres.CleanArtistName(name), sortName: postgres.SortName(name)}
+	s.artists = append(s.artists, a)
+
+	return a
+}
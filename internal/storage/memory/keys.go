@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+type apiKey struct {
+	key  postgres.APIKey
+	hash string
+}
+
+func (s *Storage) CreateKey(ctx context.Context, name, role, prefix, hash string, log *slog.Logger) (postgres.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.hash == hash {
+			return postgres.APIKey{}, fmt.Errorf("api key %q already exists", k.key.Prefix)
+		}
+	}
+
+	s.nextKeyID++
+	key := postgres.APIKey{ID: s.nextKeyID, Name: name, Prefix: prefix, Role: role, CreatedAt: time.Now()}
+	s.keys = append(s.keys, apiKey{key: key, hash: hash})
+
+	return key, nil
+}
+
+func (s *Storage) GetKeyByHash(ctx context.Context, hash string, log *slog.Logger) (postgres.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if k.hash == hash {
+			return k.key, nil
+		}
+	}
+
+	return postgres.APIKey{}, postgres.ErrKeyNotFound
+}
+
+func (s *Storage) ListKeys(ctx context.Context, log *slog.Logger) ([]postgres.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]postgres.APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k.key)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) DeleteKey(ctx context.Context, id int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, k := range s.keys {
+		if k.key.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return nil
+		}
+	}
+
+	return postgres.ErrKeyNotFound
+}
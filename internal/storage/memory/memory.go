@@ -0,0 +1,380 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/migrations"
+	"songLibrary/internal/storage/postgres"
+	"sync"
+	"time"
+)
+
+// song is a song of the user library or the catalog. Its group is the name of the artist
+// it references, read when the song is returned.
+type song struct {
+	id       int
+	artistID int
+	name     string
+	info     postgres.InfoSong
+
+	// Only user library songs carry tags and genres.
+	tags   []string
+	genres []int
+
+	// deletedAt is set while the song is in the trash.
+	deletedAt time.Time
+
+	// revisions of the info of a user library song, oldest first.
+	revisions []postgres.Revision
+
+	// version counts the changes to a user library song, its info or its artist name.
+	version int
+}
+
+// Storage keeps the user library and the global Library catalog in process memory.
+type Storage struct {
+	mu      sync.RWMutex
+	nextID  int
+	songs   []*song
+	catalog []*song
+
+	// trash holds deleted user library songs. Playlists, albums, ratings and plays keep
+	// referring to them, but find only sees the library, so they stay hidden.
+	trash []*song
+
+	nextArtistID int
+	artists      []*artist
+
+	nextKeyID int
+	keys      []apiKey
+
+	nextPlaylistID int
+	playlists      []*playlist
+
+	nextAlbumID int
+	albums      []*album
+
+	nextGenreID int
+	genres      []postgres.Genre
+
+	favorites []favorite
+	ratings   []rating
+
+	plays []postgres.Play
+}
+
+func NewStorage() *Storage {
+	return &Storage{nextID: 1}
+}
+
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) AddSong(ctx context.Context, sg postgres.Song, info postgres.InfoSong, log *slog.Logger) (postgres.Songs, error) {
+	const op = "storage.memory.AddSong()"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.resolveArtist(sg.Group)
+
+	for _, existing := range s.songs {
+		if existing.artistID == a.id && existing.name == sg.Name {
+			log.Warn("Song already exists", "song", sg.Name, "group", a.name, "operation", op)
+			return postgres.Songs{}, postgres.ErrSongExists
+		}
+	}
+	for _, trashed := range s.trash {
+		if trashed.artistID == a.id && trashed.name == sg.Name {
+			return postgres.Songs{}, postgres.ErrSongInTrash
+		}
+	}
+
+	added := &song{id: s.nextID, artistID: a.id, name: sg.Name, info: info, version: 1}
+	s.nextID++
+	s.songs = append(s.songs, added)
+
+	return s.view(added), nil
+}
+
+func (s *Storage) FindSong(ctx context.Context, group, name string, log *slog.Logger) (postgres.Songs, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if a := s.findArtist(group); a != nil {
+		for _, sg := range s.songs {
+			if sg.artistID == a.id && sg.name == name {
+				return s.view(sg), nil
+			}
+		}
+	}
+
+	return postgres.Songs{}, postgres.ErrSongNotFound
+}
+
+// ExportLibrary calls fn with a snapshot of the library taken under the lock, so a slow
+// reader of the export does not hold up writers.
+func (s *Storage) ExportLibrary(ctx context.Context, lyrics bool, fn func(song postgres.Songs) error, log *slog.Logger) error {
+	s.mu.RLock()
+	songs := make([]postgres.Songs, 0, len(s.songs))
+	for _, sg := range s.songs {
+		view := s.view(sg)
+		if !lyrics {
+			view.InfoSong.Text = ""
+		}
+		songs = append(songs, view)
+	}
+	s.mu.RUnlock()
+
+	for _, song := range songs {
+		if err := fn(song); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) ChangeInfo(ctx context.Context, id int, info postgres.InfoSong, editor string, ifVersion int, log *slog.Logger) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg, err := s.findVersion(id, ifVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	after := sg.info
+	if info.ReleaseDate != nil {
+		after.ReleaseDate = info.ReleaseDate
+	}
+	after.Text = info.Text
+	after.Link = info.Link
+
+	sg.change(after, editor, nil)
+
+	return sg.version, nil
+}
+
+// DeleteSong moves a song to the trash.
+func (s *Storage) DeleteSong(ctx context.Context, id, ifVersion int, log *slog.Logger) (sql.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, sg := range s.songs {
+		if sg.id == id {
+			if ifVersion != 0 && ifVersion != sg.version {
+				return nil, postgres.ErrVersionMismatch
+			}
+			s.songs = append(s.songs[:i], s.songs[i+1:]...)
+			sg.deletedAt = time.Now()
+			s.trash = append(s.trash, sg)
+			return driver.RowsAffected(1), nil
+		}
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (s *Storage) GetSong(ctx context.Context, id int, log *slog.Logger) (postgres.Songs, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sg := s.find(id)
+	if sg == nil {
+		return postgres.Songs{}, 0, postgres.ErrSongNotFound
+	}
+
+	return s.view(sg), sg.version, nil
+}
+
+func (s *Storage) GetText(ctx context.Context, id int, log *slog.Logger) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sg := s.find(id)
+	if sg == nil {
+		return "", postgres.ErrSongNotFound
+	}
+
+	return sg.info.Text, nil
+}
+
+func (s *Storage) GetLibrary(ctx context.Context, q postgres.LibraryQuery, log *slog.Logger) (postgres.LibraryPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.library(q, s.songs), nil
+}
+
+func (s *Storage) GetInfo(ctx context.Context, group, song string, log *slog.Logger) (postgres.InfoSong, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a := s.findArtist(group)
+	if a == nil {
+		return postgres.InfoSong{}, postgres.ErrSongNotFound
+	}
+
+	for _, entry := range s.catalog {
+		if entry.artistID == a.id && entry.name == song {
+			return entry.info, nil
+		}
+	}
+
+	return postgres.InfoSong{}, postgres.ErrSongNotFound
+}
+
+func (s *Storage) CountSongs(ctx context.Context, log *slog.Logger) (postgres.SongCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return postgres.SongCounts{Library: len(s.songs), Trash: len(s.trash), Catalog: len(s.catalog)}, nil
+}
+
+func (s *Storage) GetLibraryMain(ctx context.Context, q postgres.LibraryQuery, log *slog.Logger) (postgres.LibraryPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Albums hold user library songs only.
+	q.AlbumDates = false
+
+	return s.library(q, s.catalog), nil
+}
+
+// Seed fills the global catalog from the fixture the PostgreSQL seed migrations load.
+func (s *Storage) Seed(log *slog.Logger) {
+	const op = "storage.memory.Seed()"
+
+	library, err := migrations.Library()
+	if err != nil {
+		log.Error("Error to load the Library fixture", "error", err, "operation", op)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, aliases := range knownAliases {
+		a := s.resolveArtist(name)
+		a.aliases = append(a.aliases, aliases...)
+	}
+
+	for _, entry := range library {
+		releaseDate, err := time.Parse(time.DateOnly, entry.ReleaseDate)
+		if err != nil {
+			log.Error("Error to parse release date", "error", err, "song", entry.Song, "operation", op)
+			continue
+		}
+
+		a := s.resolveArtist(entry.Group)
+		if !s.inCatalog(a.id, entry.Song) {
+			s.catalog = append(s.catalog, &song{
+				id:       len(s.catalog) + 1,
+				artistID: a.id,
+				name:     entry.Song,
+				info: postgres.InfoSong{
+					ReleaseDate: &releaseDate,
+					Text:        entry.Text,
+					Link:        entry.Link,
+				},
+			})
+		}
+	}
+}
+
+func (s *Storage) find(id int) *song {
+	for _, sg := range s.songs {
+		if sg.id == id {
+			return sg
+		}
+	}
+	return nil
+}
+
+// findVersion returns the library song with the id, checking that it is at the version
+// unless that is 0.
+func (s *Storage) findVersion(id, ifVersion int) (*song, error) {
+	sg := s.find(id)
+	if sg == nil {
+		return nil, postgres.ErrSongNotFound
+	}
+	if ifVersion != 0 && ifVersion != sg.version {
+		return nil, postgres.ErrVersionMismatch
+	}
+	return sg, nil
+}
+
+func (s *Storage) inCatalog(artistID int, name string) bool {
+	for _, entry := range s.catalog {
+		if entry.artistID == artistID && entry.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// view returns a song as the API shows it, with the current name of its artist.
+func (s *Storage) view(sg *song) postgres.Songs {
+	return postgres.Songs{
+		ID:       sg.id,
+		Song:     postgres.Song{Group: s.artist(sg.artistID).name, Name: sg.name},
+		InfoSong: sg.info,
+	}
+}
+
+// library narrows songs to the artist q asks for, by ID or by name or alias, and pages them.
+func (s *Storage) library(q postgres.LibraryQuery, songs []*song) postgres.LibraryPage {
+	artistID := q.ArtistID
+	if q.Group != "" {
+		a := s.findArtist(q.Group)
+		if a == nil || (artistID != 0 && artistID != a.id) {
+			artistID = -1
+		} else {
+			artistID = a.id
+		}
+		q.Group = ""
+	}
+
+	var subtree map[int]bool
+	if q.Genre != "" {
+		subtree = s.genreSubtree(q.Genre)
+	}
+	tag := postgres.NormalizeTag(q.Tag)
+
+	views := make([]postgres.Songs, 0, len(songs))
+	users := make(map[int]*song, len(songs))
+	for _, sg := range songs {
+		if artistID != 0 && sg.artistID != artistID {
+			continue
+		}
+
+		u := s.userSong(sg)
+		if tag != "" && (u == nil || !slices.Contains(u.tags, tag)) {
+			continue
+		}
+		if q.Genre != "" && (u == nil || !slices.ContainsFunc(u.genres, func(id int) bool { return subtree[id] })) {
+			continue
+		}
+
+		view := s.view(sg)
+		if q.AlbumDates && view.InfoSong.ReleaseDate == nil {
+			view.InfoSong.ReleaseDate = s.albumReleaseDate(sg.id)
+		}
+		views = append(views, view)
+		users[sg.id] = u
+	}
+
+	page := libraryPage(q, views)
+	for i := range page.Items {
+		u := users[page.Items[i].Songs.ID]
+		page.Items[i].Tags, page.Items[i].Genres = s.labels(u)
+		page.Items[i].AverageRating, page.Items[i].RatingCount = s.rating(u)
+	}
+
+	return page
+}
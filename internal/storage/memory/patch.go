@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"songLibrary/internal/storage/postgres"
+)
+
+func (s *Storage) PatchSong(ctx context.Context, id int, patch postgres.SongPatch, editor string, ifVersion int, log *slog.Logger) (postgres.Songs, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg, err := s.findVersion(id, ifVersion)
+	if err != nil {
+		return postgres.Songs{}, 0, err
+	}
+
+	artistID, name := sg.artistID, sg.name
+	if patch.Group != nil {
+		artistID = s.resolveArtist(*patch.Group).id
+	}
+	if patch.Name != nil {
+		name = *patch.Name
+	}
+
+	renamed := artistID != sg.artistID || name != sg.name
+	if renamed {
+		for _, other := range s.songs {
+			if other.artistID == artistID && other.name == name {
+				return postgres.Songs{}, 0, postgres.ErrSongExists
+			}
+		}
+		for _, trashed := range s.trash {
+			if trashed.artistID == artistID && trashed.name == name {
+				return postgres.Songs{}, 0, postgres.ErrSongInTrash
+			}
+		}
+		sg.artistID, sg.name = artistID, name
+	}
+
+	if rev := sg.change(patch.Apply(sg.info), editor, nil); rev.Revision == 0 && renamed {
+		sg.version++
+	}
+
+	return s.view(sg), sg.version, nil
+}
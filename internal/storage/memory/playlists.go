@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+type playlist struct {
+	id        int
+	name      string
+	createdAt time.Time
+	songs     []int
+}
+
+// playlistView returns a playlist as the API shows it, counting only the songs in the library.
+func (s *Storage) playlistView(p *playlist) postgres.Playlist {
+	count := 0
+	for _, songID := range p.songs {
+		if s.find(songID) != nil {
+			count++
+		}
+	}
+	return postgres.Playlist{ID: p.id, Name: p.name, CreatedAt: p.createdAt, SongCount: count}
+}
+
+func (s *Storage) CreatePlaylist(ctx context.Context, name string, log *slog.Logger) (postgres.Playlist, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextPlaylistID++
+	p := &playlist{id: s.nextPlaylistID, name: name, createdAt: time.Now()}
+	s.playlists = append(s.playlists, p)
+
+	return s.playlistView(p), nil
+}
+
+func (s *Storage) ListPlaylists(ctx context.Context, log *slog.Logger) ([]postgres.Playlist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	playlists := make([]postgres.Playlist, 0, len(s.playlists))
+	for _, p := range s.playlists {
+		playlists = append(playlists, s.playlistView(p))
+	}
+
+	return playlists, nil
+}
+
+func (s *Storage) GetPlaylist(ctx context.Context, id int, log *slog.Logger) (postgres.PlaylistDetails, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p := s.findPlaylist(id)
+	if p == nil {
+		return postgres.PlaylistDetails{}, postgres.ErrPlaylistNotFound
+	}
+
+	details := postgres.PlaylistDetails{Playlist: s.playlistView(p), Entries: []postgres.PlaylistEntry{}}
+	for _, songID := range p.songs {
+		// Trashed songs keep their place but are not shown.
+		if sg := s.find(songID); sg != nil {
+			details.Entries = append(details.Entries, postgres.PlaylistEntry{
+				Position: len(details.Entries) + 1,
+				Songs:    s.view(sg),
+			})
+		}
+	}
+
+	return details, nil
+}
+
+func (s *Storage) RenamePlaylist(ctx context.Context, id int, name string, log *slog.Logger) (postgres.Playlist, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.findPlaylist(id)
+	if p == nil {
+		return postgres.Playlist{}, postgres.ErrPlaylistNotFound
+	}
+	p.name = name
+
+	return s.playlistView(p), nil
+}
+
+func (s *Storage) DeletePlaylist(ctx context.Context, id int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.playlists {
+		if p.id == id {
+			s.playlists = append(s.playlists[:i], s.playlists[i+1:]...)
+			return nil
+		}
+	}
+
+	return postgres.ErrPlaylistNotFound
+}
+
+func (s *Storage) AddToPlaylist(ctx context.Context, id, songID, position int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.findPlaylist(id)
+	if p == nil {
+		return postgres.ErrPlaylistNotFound
+	}
+	if s.find(songID) == nil {
+		return postgres.ErrSongNotFound
+	}
+	if slices.Contains(p.songs, songID) {
+		return postgres.ErrSongInPlaylist
+	}
+
+	s.reorder(p, postgres.MoveSong(s.listed(p), songID, position))
+
+	return nil
+}
+
+func (s *Storage) RemoveFromPlaylist(ctx context.Context, id, songID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.findPlaylist(id)
+	if p == nil {
+		return postgres.ErrPlaylistNotFound
+	}
+	order := s.listed(p)
+	if !slices.Contains(order, songID) {
+		return postgres.ErrSongNotInList
+	}
+
+	s.reorder(p, slices.DeleteFunc(order, func(sid int) bool { return sid == songID }))
+
+	return nil
+}
+
+func (s *Storage) MovePlaylistSong(ctx context.Context, id, songID, position int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.findPlaylist(id)
+	if p == nil {
+		return postgres.ErrPlaylistNotFound
+	}
+	order := s.listed(p)
+	if !slices.Contains(order, songID) {
+		return postgres.ErrSongNotInList
+	}
+
+	s.reorder(p, postgres.MoveSong(order, songID, position))
+
+	return nil
+}
+
+// listed returns the library songs of a playlist in order, the ones GetPlaylist shows and
+// positions count.
+func (s *Storage) listed(p *playlist) []int {
+	return slices.DeleteFunc(slices.Clone(p.songs), func(songID int) bool { return s.find(songID) == nil })
+}
+
+// reorder puts the library songs of a playlist in the order given, keeping trashed songs in
+// their place among them.
+func (s *Storage) reorder(p *playlist, order []int) {
+	p.songs = postgres.KeepHidden(p.songs, func(songID int) bool { return s.find(songID) != nil }, order)
+}
+
+func (s *Storage) findPlaylist(id int) *playlist {
+	for _, p := range s.playlists {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// dropFromPlaylists removes a purged song from every playlist, as the foreign key cascade
+// does in PostgreSQL.
+func (s *Storage) dropFromPlaylists(songID int) {
+	for _, p := range s.playlists {
+		p.songs = slices.DeleteFunc(p.songs, func(sid int) bool { return sid == songID })
+	}
+}
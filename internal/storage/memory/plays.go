@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+func (s *Storage) AddPlays(ctx context.Context, plays []postgres.Play, log *slog.Logger) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, p := range plays {
+		if s.find(p.SongID) == nil {
+			continue
+		}
+		s.plays = append(s.plays, p)
+		n++
+	}
+
+	return n, nil
+}
+
+func (s *Storage) RecentPlays(ctx context.Context, limit int, log *slog.Logger) ([]postgres.RecentPlay, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Plays are kept in the order they were written, which is not always the order they
+	// were played in.
+	order := make([]int, len(s.plays))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if c := s.plays[b].PlayedAt.Compare(s.plays[a].PlayedAt); c != 0 {
+			return c
+		}
+		return b - a
+	})
+
+	plays := []postgres.RecentPlay{}
+	for _, i := range order {
+		if len(plays) == limit {
+			break
+		}
+		p := s.plays[i]
+		if sg := s.find(p.SongID); sg != nil {
+			plays = append(plays, postgres.RecentPlay{Songs: s.view(sg), PlayedAt: p.PlayedAt, Duration: p.Duration})
+		}
+	}
+
+	return plays, nil
+}
+
+func (s *Storage) MostPlayedSongs(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.PlayedSong, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[int]*postgres.PlayedSong)
+	for _, p := range s.window(q) {
+		ps := counts[p.SongID]
+		if ps == nil {
+			ps = &postgres.PlayedSong{Songs: s.view(s.find(p.SongID))}
+			counts[p.SongID] = ps
+		}
+		ps.Plays++
+		if p.Duration != nil {
+			ps.Listened += *p.Duration
+		}
+	}
+
+	songs := make([]postgres.PlayedSong, 0, len(counts))
+	for _, ps := range counts {
+		songs = append(songs, *ps)
+	}
+	slices.SortFunc(songs, func(a, b postgres.PlayedSong) int {
+		return cmp.Or(b.Plays-a.Plays, a.Songs.ID-b.Songs.ID)
+	})
+
+	return songs[:min(q.Limit, len(songs))], nil
+}
+
+func (s *Storage) MostPlayedArtists(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.PlayedArtist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[int]*postgres.PlayedArtist)
+	for _, p := range s.window(q) {
+		a := s.artist(s.find(p.SongID).artistID)
+		pa := counts[a.id]
+		if pa == nil {
+			pa = &postgres.PlayedArtist{ArtistID: a.id, Artist: a.name}
+			counts[a.id] = pa
+		}
+		pa.Plays++
+	}
+
+	artists := make([]postgres.PlayedArtist, 0, len(counts))
+	for _, pa := range counts {
+		artists = append(artists, *pa)
+	}
+	slices.SortFunc(artists, func(a, b postgres.PlayedArtist) int {
+		return cmp.Or(b.Plays-a.Plays, a.ArtistID-b.ArtistID)
+	})
+
+	return artists[:min(q.Limit, len(artists))], nil
+}
+
+func (s *Storage) DailyPlays(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.DayPlays, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, p := range s.window(q) {
+		counts[p.PlayedAt.UTC().Format(time.DateOnly)]++
+	}
+
+	return postgres.FillDays(q, counts), nil
+}
+
+// window returns the plays in the window of q of songs in the library, not the trash.
+func (s *Storage) window(q postgres.PlayQuery) []postgres.Play {
+	var plays []postgres.Play
+	for _, p := range s.plays {
+		if !p.PlayedAt.Before(q.From) && p.PlayedAt.Before(q.To) && s.find(p.SongID) != nil {
+			plays = append(plays, p)
+		}
+	}
+	return plays
+}
+
+// dropPlays removes the plays of a purged song.
+func (s *Storage) dropPlays(songID int) {
+	s.plays = slices.DeleteFunc(s.plays, func(p postgres.Play) bool { return p.SongID == songID })
+}
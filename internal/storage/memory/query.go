@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"cmp"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// libraryPage applies the filters, sort order and page of q to songs the same way the
+// PostgreSQL storage does in SQL.
+func libraryPage(q postgres.LibraryQuery, songs []postgres.Songs) postgres.LibraryPage {
+	var matched []postgres.Songs
+	for _, sg := range songs {
+		if matches(q, sg) {
+			matched = append(matched, sg)
+		}
+	}
+
+	compare := func(a, b postgres.Songs) int {
+		c := compareSortValues(q.SortBy, a, b)
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if q.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(matched, compare)
+
+	total := len(matched)
+
+	if q.Cursor != nil {
+		start := len(matched)
+		for i, sg := range matched {
+			c := compareSortValue(q.SortBy, postgres.SortValue(q.SortBy, sg), q.Cursor.Value)
+			if c == 0 {
+				c = cmp.Compare(sg.ID, q.Cursor.ID)
+			}
+			if q.Desc {
+				c = -c
+			}
+			if c > 0 {
+				start = i
+				break
+			}
+		}
+		matched = matched[start:]
+	}
+
+	if q.Offset >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[q.Offset:]
+	}
+	if len(matched) > q.Limit+1 {
+		matched = matched[:q.Limit+1]
+	}
+
+	items := make([]postgres.Library, 0, len(matched))
+	for _, sg := range matched {
+		items = append(items, postgres.Library{Songs: sg})
+	}
+
+	return postgres.FinishPage(q, items, total)
+}
+
+func matches(q postgres.LibraryQuery, sg postgres.Songs) bool {
+	if q.Group != "" && !strings.EqualFold(sg.Song.Group, q.Group) {
+		return false
+	}
+	if q.Song != "" && !strings.Contains(strings.ToLower(sg.Song.Name), strings.ToLower(q.Song)) {
+		return false
+	}
+
+	date := sg.InfoSong.ReleaseDate
+	if q.ReleasedFrom != nil && (date == nil || dateOnly(*date).Before(dateOnly(*q.ReleasedFrom))) {
+		return false
+	}
+	if q.ReleasedTo != nil && (date == nil || dateOnly(*date).After(dateOnly(*q.ReleasedTo))) {
+		return false
+	}
+
+	if q.HasLink != nil && (sg.InfoSong.Link != "") != *q.HasLink {
+		return false
+	}
+
+	return true
+}
+
+func compareSortValues(sortBy string, a, b postgres.Songs) int {
+	return compareSortValue(sortBy, postgres.SortValue(sortBy, a), postgres.SortValue(sortBy, b))
+}
+
+// compareSortValue orders two cursor values; ids compare as numbers, everything else,
+// including the YYYY-MM-DD release dates, as strings.
+func compareSortValue(sortBy, a, b string) int {
+	if sortBy == postgres.SortID {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(a, b)
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
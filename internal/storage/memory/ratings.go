@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+type favorite struct {
+	userID  string
+	songID  int
+	addedAt time.Time
+}
+
+type rating struct {
+	userID  string
+	songID  int
+	stars   int
+	ratedAt time.Time
+}
+
+func (s *Storage) AddFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(songID) == nil {
+		return postgres.ErrSongNotFound
+	}
+
+	if slices.ContainsFunc(s.favorites, func(f favorite) bool { return f.userID == userID && f.songID == songID }) {
+		return nil
+	}
+	s.favorites = append(s.favorites, favorite{userID: userID, songID: songID, addedAt: time.Now()})
+
+	return nil
+}
+
+func (s *Storage) RemoveFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.favorites = slices.DeleteFunc(s.favorites, func(f favorite) bool { return f.userID == userID && f.songID == songID })
+
+	return nil
+}
+
+func (s *Storage) ListFavorites(ctx context.Context, userID string, log *slog.Logger) ([]postgres.Favorite, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	favorites := []postgres.Favorite{}
+	for _, f := range slices.Backward(s.favorites) {
+		if sg := s.find(f.songID); sg != nil && f.userID == userID {
+			favorites = append(favorites, postgres.Favorite{Songs: s.view(sg), AddedAt: f.addedAt})
+		}
+	}
+
+	return favorites, nil
+}
+
+func (s *Storage) RateSong(ctx context.Context, userID string, songID, stars int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(songID) == nil {
+		return postgres.ErrSongNotFound
+	}
+
+	// A new rating moves to the end, so the ratings stay in the order they were given.
+	s.ratings = slices.DeleteFunc(s.ratings, func(r rating) bool { return r.userID == userID && r.songID == songID })
+	s.ratings = append(s.ratings, rating{userID: userID, songID: songID, stars: stars, ratedAt: time.Now()})
+
+	return nil
+}
+
+func (s *Storage) RemoveRating(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.ratings)
+	s.ratings = slices.DeleteFunc(s.ratings, func(r rating) bool { return r.userID == userID && r.songID == songID })
+	if len(s.ratings) == n {
+		return postgres.ErrNotRated
+	}
+
+	return nil
+}
+
+func (s *Storage) ListRatings(ctx context.Context, userID string, log *slog.Logger) ([]postgres.Rating, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ratings := []postgres.Rating{}
+	for _, r := range slices.Backward(s.ratings) {
+		if sg := s.find(r.songID); sg != nil && r.userID == userID {
+			ratings = append(ratings, postgres.Rating{Songs: s.view(sg), Stars: r.stars, RatedAt: r.ratedAt})
+		}
+	}
+
+	return ratings, nil
+}
+
+func (s *Storage) TopRated(ctx context.Context, q postgres.TopRatedQuery, log *slog.Logger) ([]postgres.RatedSong, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	artistID := q.ArtistID
+	if q.Group != "" {
+		a := s.findArtist(q.Group)
+		if a == nil || (artistID != 0 && artistID != a.id) {
+			return []postgres.RatedSong{}, nil
+		}
+		artistID = a.id
+	}
+
+	songs := []postgres.RatedSong{}
+	for _, sg := range s.songs {
+		if artistID != 0 && sg.artistID != artistID {
+			continue
+		}
+		if q.Year != 0 && (sg.info.ReleaseDate == nil || sg.info.ReleaseDate.Year() != q.Year) {
+			continue
+		}
+
+		average, count := s.rating(sg)
+		if count == 0 {
+			continue
+		}
+		songs = append(songs, postgres.RatedSong{Songs: s.view(sg), AverageRating: *average, RatingCount: count})
+	}
+
+	slices.SortStableFunc(songs, func(a, b postgres.RatedSong) int {
+		switch {
+		case a.AverageRating != b.AverageRating:
+			if a.AverageRating > b.AverageRating {
+				return -1
+			}
+			return 1
+		case a.RatingCount != b.RatingCount:
+			return b.RatingCount - a.RatingCount
+		}
+		return a.Songs.ID - b.Songs.ID
+	})
+
+	return songs[:min(q.Limit, len(songs))], nil
+}
+
+// rating returns the average, rounded to two decimals, and the number of the ratings of a
+// user library song. The average is nil when nobody rated it or there is no such song.
+func (s *Storage) rating(u *song) (*float64, int) {
+	if u == nil {
+		return nil, 0
+	}
+
+	var sum, count int
+	for _, r := range s.ratings {
+		if r.songID == u.id {
+			sum += r.stars
+			count++
+		}
+	}
+	if count == 0 {
+		return nil, 0
+	}
+
+	average := math.Round(float64(sum)/float64(count)*100) / 100
+	return &average, count
+}
+
+// dropRatings removes the favorites and ratings of a purged song.
+func (s *Storage) dropRatings(songID int) {
+	s.favorites = slices.DeleteFunc(s.favorites, func(f favorite) bool { return f.songID == songID })
+	s.ratings = slices.DeleteFunc(s.ratings, func(r rating) bool { return r.songID == songID })
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+// change sets the info of a song to after and records it as a revision, keeping the info
+// before the first change as revision 1. Nothing is recorded when after changes nothing;
+// the zero revision is returned then.
+func (sg *song) change(after postgres.InfoSong, editor string, rollbackOf *int) postgres.Revision {
+	fields := postgres.ChangedFields(sg.info, after)
+	if len(fields) == 0 {
+		return postgres.Revision{}
+	}
+
+	now := time.Now()
+	if len(sg.revisions) == 0 {
+		sg.revisions = append(sg.revisions, postgres.Revision{
+			Revision:      1,
+			SongID:        sg.id,
+			Version:       sg.version,
+			InfoSong:      sg.info,
+			ChangedFields: []string{},
+			CreatedAt:     now,
+		})
+	}
+
+	sg.version++
+	rev := postgres.Revision{
+		Revision:      len(sg.revisions) + 1,
+		SongID:        sg.id,
+		Version:       sg.version,
+		InfoSong:      after,
+		ChangedFields: fields,
+		Editor:        editor,
+		RollbackOf:    rollbackOf,
+		CreatedAt:     now,
+	}
+	sg.revisions = append(sg.revisions, rev)
+	sg.info = after
+
+	return rev
+}
+
+func (s *Storage) ListRevisions(ctx context.Context, songID int, log *slog.Logger) ([]postgres.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sg := s.find(songID)
+	if sg == nil {
+		return nil, postgres.ErrSongNotFound
+	}
+
+	revisions := slices.Clone(sg.revisions)
+	slices.Reverse(revisions)
+	if revisions == nil {
+		revisions = []postgres.Revision{}
+	}
+
+	return revisions, nil
+}
+
+func (s *Storage) GetRevision(ctx context.Context, songID, revision int, log *slog.Logger) (postgres.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.revision(songID, revision)
+}
+
+func (s *Storage) RollbackInfo(ctx context.Context, songID, revision int, editor string, ifVersion int, log *slog.Logger) (postgres.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg, err := s.findVersion(songID, ifVersion)
+	if err != nil {
+		return postgres.Revision{}, err
+	}
+
+	target, err := s.revision(songID, revision)
+	if err != nil {
+		return postgres.Revision{}, err
+	}
+
+	rev := sg.change(target.InfoSong, editor, &revision)
+	if rev.Revision == 0 {
+		return postgres.Revision{}, postgres.ErrRevisionCurrent
+	}
+
+	return rev, nil
+}
+
+func (s *Storage) revision(songID, revision int) (postgres.Revision, error) {
+	sg := s.find(songID)
+	if sg == nil || revision < 1 || revision > len(sg.revisions) {
+		return postgres.Revision{}, postgres.ErrRevisionNotFound
+	}
+	return sg.revisions[revision-1], nil
+}
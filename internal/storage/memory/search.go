@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"regexp"
+	"slices"
+	"songLibrary/internal/lyrics"
+	"songLibrary/internal/storage/postgres"
+	"strings"
+	"unicode"
+)
+
+// Search approximates the PostgreSQL full-text search: every word of the query has to occur
+// in the group, title or lyrics, case-insensitively. There is no stemming, so Lang only
+// has to be valid.
+func (s *Storage) Search(ctx context.Context, q postgres.SearchQuery, log *slog.Logger) ([]postgres.SearchResult, error) {
+	words := strings.FieldsFunc(strings.ToLower(q.Text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return []postgres.SearchResult{}, nil
+	}
+
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	pattern := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := []postgres.SearchResult{}
+
+	match := func(source string, sg postgres.Songs) {
+		haystack := strings.ToLower(sg.Song.Group + "\n" + sg.Song.Name + "\n" + sg.InfoSong.Text)
+		for _, w := range words {
+			if !strings.Contains(haystack, w) {
+				return
+			}
+		}
+
+		res := postgres.SearchResult{
+			Source: source,
+			ID:     sg.ID,
+			Song:   sg.Song,
+			Rank:   float64(len(pattern.FindAllStringIndex(haystack, -1))),
+			Lines:  []postgres.LineMatch{},
+		}
+
+		var fragments []string
+		for _, line := range lyrics.Lines(lyrics.Split(sg.InfoSong.Text)) {
+			matches := pattern.FindAllStringIndex(line.Text, -1)
+			if matches == nil {
+				continue
+			}
+			highlighted := postgres.Highlight(line.Text, matches)
+			res.Lines = append(res.Lines, postgres.LineMatch{Number: line.Number, Text: highlighted})
+			if len(fragments) < 2 {
+				fragments = append(fragments, highlighted)
+			}
+		}
+		res.Snippet = strings.Join(fragments, " ... ")
+
+		results = append(results, res)
+	}
+
+	if q.Scope != postgres.SearchCatalog {
+		for _, sg := range s.songs {
+			match(postgres.SearchLibrary, s.view(sg))
+		}
+	}
+	if q.Scope != postgres.SearchLibrary {
+		for _, entry := range s.catalog {
+			match(postgres.SearchCatalog, s.view(entry))
+		}
+	}
+
+	slices.SortStableFunc(results, func(a, b postgres.SearchResult) int {
+		return cmp.Compare(b.Rank, a.Rank)
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	return results, nil
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"strings"
+)
+
+func (s *Storage) CreateGenre(ctx context.Context, name string, parentID *int, log *slog.Logger) (postgres.Genre, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findGenre(name) != nil {
+		return postgres.Genre{}, postgres.ErrGenreExists
+	}
+	if parentID != nil && s.genre(*parentID) == nil {
+		return postgres.Genre{}, postgres.ErrGenreNotFound
+	}
+
+	s.nextGenreID++
+	g := postgres.Genre{ID: s.nextGenreID, Name: name, ParentID: parentID}
+	s.genres = append(s.genres, g)
+
+	return g, nil
+}
+
+func (s *Storage) ListGenres(ctx context.Context, log *slog.Logger) ([]postgres.Genre, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]postgres.Genre{}, s.genres...), nil
+}
+
+func (s *Storage) AddSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg := s.find(songID)
+	if sg == nil {
+		return postgres.ErrSongNotFound
+	}
+	if s.genre(genreID) == nil {
+		return postgres.ErrGenreNotFound
+	}
+
+	if !slices.Contains(sg.genres, genreID) {
+		sg.genres = append(sg.genres, genreID)
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sg := s.find(songID); sg != nil {
+		sg.genres = slices.DeleteFunc(sg.genres, func(id int) bool { return id == genreID })
+	}
+
+	return nil
+}
+
+func (s *Storage) AddSongTags(ctx context.Context, songID int, tags []string, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sg := s.find(songID)
+	if sg == nil {
+		return postgres.ErrSongNotFound
+	}
+
+	for _, tag := range tags {
+		if !slices.Contains(sg.tags, tag) {
+			sg.tags = append(sg.tags, tag)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveSongTag(ctx context.Context, songID int, tag string, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sg := s.find(songID); sg != nil {
+		sg.tags = slices.DeleteFunc(sg.tags, func(t string) bool { return t == tag })
+	}
+
+	return nil
+}
+
+func (s *Storage) TagCounts(ctx context.Context, log *slog.Logger) ([]postgres.TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{}
+	for _, sg := range s.songs {
+		for _, tag := range sg.tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]postgres.TagCount, 0, len(counts))
+	for tag, n := range counts {
+		result = append(result, postgres.TagCount{Tag: tag, Count: n})
+	}
+
+	slices.SortFunc(result, func(a, b postgres.TagCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return result, nil
+}
+
+func (s *Storage) genre(id int) *postgres.Genre {
+	for i := range s.genres {
+		if s.genres[i].ID == id {
+			return &s.genres[i]
+		}
+	}
+	return nil
+}
+
+func (s *Storage) findGenre(name string) *postgres.Genre {
+	for i := range s.genres {
+		if strings.EqualFold(s.genres[i].Name, name) {
+			return &s.genres[i]
+		}
+	}
+	return nil
+}
+
+// genreSubtree returns the genre named name and all of its subgenres, like genre_subtree() in SQL.
+func (s *Storage) genreSubtree(name string) map[int]bool {
+	root := s.findGenre(name)
+	if root == nil {
+		return nil
+	}
+
+	subtree := map[int]bool{root.ID: true}
+	for grown := true; grown; {
+		grown = false
+		for _, g := range s.genres {
+			if g.ParentID != nil && subtree[*g.ParentID] && !subtree[g.ID] {
+				subtree[g.ID] = true
+				grown = true
+			}
+		}
+	}
+
+	return subtree
+}
+
+// userSong returns the user library song a library or catalog song shares its tags and
+// genres with: the same song by the same artist.
+func (s *Storage) userSong(sg *song) *song {
+	for _, u := range s.songs {
+		if u.artistID == sg.artistID && u.name == sg.name {
+			return u
+		}
+	}
+	return nil
+}
+
+// labels returns the sorted tags and genre names of a user library song, empty if there is none.
+func (s *Storage) labels(u *song) ([]string, []string) {
+	tags, genres := []string{}, []string{}
+	if u == nil {
+		return tags, genres
+	}
+
+	tags = append(tags, u.tags...)
+	slices.Sort(tags)
+
+	for _, id := range u.genres {
+		if g := s.genre(id); g != nil {
+			genres = append(genres, g.Name)
+		}
+	}
+	slices.Sort(genres)
+
+	return tags, genres
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+func (s *Storage) ListTrash(ctx context.Context, log *slog.Logger) ([]postgres.TrashedSong, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trash := make([]postgres.TrashedSong, 0, len(s.trash))
+	for _, sg := range slices.Backward(s.trash) {
+		trash = append(trash, postgres.TrashedSong{Songs: s.view(sg), DeletedAt: sg.deletedAt})
+	}
+
+	return trash, nil
+}
+
+func (s *Storage) RestoreSong(ctx context.Context, id int, log *slog.Logger) (postgres.Songs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.trash, func(sg *song) bool { return sg.id == id })
+	if i < 0 {
+		return postgres.Songs{}, postgres.ErrSongNotFound
+	}
+
+	sg := s.trash[i]
+	s.trash = slices.Delete(s.trash, i, i+1)
+	sg.deletedAt = time.Time{}
+
+	// The library is kept in ID order, like the PostgreSQL listing.
+	at, _ := slices.BinarySearchFunc(s.songs, id, func(sg *song, id int) int { return sg.id - id })
+	s.songs = slices.Insert(s.songs, at, sg)
+
+	return s.view(sg), nil
+}
+
+func (s *Storage) PurgeSong(ctx context.Context, id int, log *slog.Logger) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.purge(func(sg *song) bool { return sg.id == id }) == 0 {
+		return postgres.ErrSongNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) PurgeTrash(ctx context.Context, before time.Time, log *slog.Logger) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purge(func(sg *song) bool { return sg.deletedAt.Before(before) }), nil
+}
+
+// purge deletes the trashed songs matching for good, with everything referencing them, and
+// returns how many.
+func (s *Storage) purge(match func(sg *song) bool) int {
+	n := 0
+	s.trash = slices.DeleteFunc(s.trash, func(sg *song) bool {
+		if !match(sg) {
+			return false
+		}
+		s.dropFromPlaylists(sg.id)
+		s.dropFromAlbums(sg.id)
+		s.dropRatings(sg.id)
+		s.dropPlays(sg.id)
+		n++
+		return true
+	})
+	return n
+}
@@ -0,0 +1,484 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed schema/*.sql seed/*.sql fixtures/*.json
+var files embed.FS
+
+// libraryFixture lists the songs of the global Library catalog. Seed 0002 takes it as $1 and
+// the in-memory storage loads it, so both start with the same catalog.
+const libraryFixture = "fixtures/library.json"
+
+type Kind string
+
+const (
+	// KindSchema migrations create and alter tables, indexes and triggers.
+	KindSchema Kind = "schema"
+	// KindSeed migrations load data, such as the global Library catalog, into an existing schema.
+	KindSeed Kind = "seed"
+)
+
+// advisoryLockKey is the pg_advisory_lock key shared by every replica running migrations.
+const advisoryLockKey int64 = 0x736f6e674c6962
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+	kind varchar(10) NOT NULL ,
+	version int NOT NULL ,
+	name varchar(100) NOT NULL ,
+	applied_at timestamptz NOT NULL DEFAULT now() ,
+	PRIMARY KEY(kind, version)
+	);`
+
+type Migration struct {
+	Kind    Kind   `json:"kind"`
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Up      string `json:"-"`
+	Down    string `json:"-"`
+	// Args are the parameters of Up, which is then a single statement.
+	Args []any `json:"-"`
+}
+
+// LibrarySong is a song of the global Library catalog as the fixture lists it.
+type LibrarySong struct {
+	Group       string `json:"music_group"`
+	Song        string `json:"song"`
+	Text        string `json:"text"`
+	ReleaseDate string `json:"releasedate"`
+	Link        string `json:"link"`
+}
+
+// args lists the migrations whose Up takes parameters, and where they come from.
+var args = map[Kind]map[int]func() ([]any, error){
+	KindSeed: {2: libraryArgs},
+}
+
+// seedAfter maps each seed migration to the schema version it runs right after, the latest
+// one when the seed was introduced. Seed 0001 fills Library as schema 0002 left it and
+// schema 0006 moves its songs to artists; seed 0002 loads the same catalog into the schema
+// of today and leaves the songs already there alone.
+var seedAfter = map[int]int{
+	1: 2,
+	2: 13,
+}
+
+// Library returns the songs of the global Library catalog the seed migrations load.
+func Library() ([]LibrarySong, error) {
+	body, err := fs.ReadFile(files, libraryFixture)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []LibrarySong
+	if err = json.Unmarshal(body, &songs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", libraryFixture, err)
+	}
+	return songs, nil
+}
+
+func libraryArgs() ([]any, error) {
+	body, err := fs.ReadFile(files, libraryFixture)
+	if err != nil {
+		return nil, err
+	}
+	return []any{string(body)}, nil
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+// Load returns the embedded migrations of the given kind ordered by version.
+func Load(kind Kind) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, string(kind))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		version, name, direction, err := parseName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := fs.ReadFile(files, path.Join(string(kind), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Kind: kind, Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s/%04d has two names: %q and %q", kind, version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s/%04d_%s needs both up and down files", kind, m.Version, m.Name)
+		}
+		if argsOf, ok := args[kind][m.Version]; ok {
+			if m.Args, err = argsOf(); err != nil {
+				return nil, fmt.Errorf("migration %s/%04d_%s: %w", kind, m.Version, m.Name, err)
+			}
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// All returns the embedded migrations in the order they are applied: schema migrations by
+// version, each followed by the seed migrations that run right after it.
+func All() ([]Migration, error) {
+	schema, err := Load(KindSchema)
+	if err != nil {
+		return nil, err
+	}
+	seeds, err := Load(KindSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mg := range seeds {
+		after, ok := seedAfter[mg.Version]
+		if !ok {
+			return nil, fmt.Errorf("seed migration %04d_%s does not say which schema version it runs after", mg.Version, mg.Name)
+		}
+		if after < 1 || after > len(schema) {
+			return nil, fmt.Errorf("seed migration %04d_%s runs after schema %04d, which is not embedded", mg.Version, mg.Name, after)
+		}
+	}
+	sort.SliceStable(seeds, func(i, j int) bool { return seedAfter[seeds[i].Version] < seedAfter[seeds[j].Version] })
+
+	all := make([]Migration, 0, len(schema)+len(seeds))
+	next := 0
+	for _, mg := range schema {
+		all = append(all, mg)
+		for next < len(seeds) && seedAfter[seeds[next].Version] <= mg.Version {
+			all = append(all, seeds[next])
+			next++
+		}
+	}
+
+	return all, nil
+}
+
+// parseName splits "0001_create_library.up.sql" into its version, name and direction.
+func parseName(file string) (int, string, string, error) {
+	base := strings.TrimSuffix(file, ".sql")
+	direction := path.Ext(base)
+	base = strings.TrimSuffix(base, direction)
+	direction = strings.TrimPrefix(direction, ".")
+
+	number, name, ok := strings.Cut(base, "_")
+	if !ok || (direction != "up" && direction != "down") {
+		return 0, "", "", fmt.Errorf("bad migration file name %q, want NNNN_name.up.sql or NNNN_name.down.sql", file)
+	}
+
+	version, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("bad migration version in %q: %w", file, err)
+	}
+
+	return version, name, direction, nil
+}
+
+// Migrator applies the embedded migrations to PostgreSQL. All work happens on a single
+// connection holding an advisory lock, so several replicas may start at the same time.
+type Migrator struct {
+	db *sql.DB
+}
+
+func NewMigrator(db *sql.DB) *Migrator {
+	return &Migrator{db: db}
+}
+
+// Up applies the pending migrations of the given kinds in the order All returns them. With
+// no kinds it applies both; otherwise it stops at the first pending migration of another
+// kind, since the ones after it may depend on it.
+func (m *Migrator) Up(log *slog.Logger, kinds ...Kind) error {
+	const op = "storage.migrations.Up()"
+
+	if len(kinds) == 0 {
+		kinds = []Kind{KindSchema, KindSeed}
+	}
+
+	return m.locked(log, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(context.Background(), conn)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := All()
+		if err != nil {
+			return err
+		}
+
+		// A migration skipped while later ones were applied can no longer run where it belongs.
+		for i, mg := range migrations {
+			if _, ok := applied[mg.Kind][mg.Version]; ok {
+				continue
+			}
+			for _, later := range migrations[i+1:] {
+				if _, ok := applied[later.Kind][later.Version]; ok {
+					return fmt.Errorf("%s migration %04d_%s is not applied but %s migration %04d_%s after it is, roll back with migrate down first",
+						mg.Kind, mg.Version, mg.Name, later.Kind, later.Version, later.Name)
+				}
+			}
+			break
+		}
+
+		for _, mg := range migrations {
+			if _, ok := applied[mg.Kind][mg.Version]; ok {
+				continue
+			}
+			if !slices.Contains(kinds, mg.Kind) {
+				log.Info("migrations stopped before a migration of another kind", "kind", mg.Kind, "version", mg.Version, "name", mg.Name)
+				return nil
+			}
+
+			err = inTx(conn, mg.Up, mg.Args,
+				`INSERT INTO schema_migrations (kind, version, name) VALUES ($1, $2, $3)`,
+				mg.Kind, mg.Version, mg.Name)
+			if err != nil {
+				log.Error("Error to apply migration", "error", err, "kind", mg.Kind, "version", mg.Version, "operation", op)
+				return fmt.Errorf("apply %s migration %04d_%s: %w", mg.Kind, mg.Version, mg.Name, err)
+			}
+			log.Info("migration applied", "kind", mg.Kind, "version", mg.Version, "name", mg.Name)
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations in the reverse of the order All returns
+// them, whatever their kind.
+func (m *Migrator) Down(log *slog.Logger, steps int) error {
+	const op = "storage.migrations.Down()"
+
+	return m.locked(log, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(context.Background(), conn)
+		if err != nil {
+			return err
+		}
+
+		migrations, err := All()
+		if err != nil {
+			return err
+		}
+
+		// Migrations of a newer binary are not known here, so nothing before them may go.
+		for kind, versions := range applied {
+			for version := range versions {
+				if !slices.ContainsFunc(migrations, func(mg Migration) bool { return mg.Kind == kind && mg.Version == version }) {
+					return fmt.Errorf("applied migration %s/%04d is not embedded in this binary", kind, version)
+				}
+			}
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			mg := migrations[i]
+			if _, ok := applied[mg.Kind][mg.Version]; !ok {
+				continue
+			}
+
+			err = inTx(conn, mg.Down, nil,
+				`DELETE FROM schema_migrations WHERE kind = $1 AND version = $2`,
+				mg.Kind, mg.Version)
+			if err != nil {
+				log.Error("Error to revert migration", "error", err, "kind", mg.Kind, "version", mg.Version, "operation", op)
+				return fmt.Errorf("revert %s migration %04d_%s: %w", mg.Kind, mg.Version, mg.Name, err)
+			}
+			log.Info("migration reverted", "kind", mg.Kind, "version", mg.Version, "name", mg.Name)
+			steps--
+		}
+
+		return nil
+	})
+}
+
+// Status lists every embedded migration in the order All returns them, together with the time it was applied, if it was.
+func (m *Migrator) Status(log *slog.Logger) ([]Status, error) {
+	var statuses []Status
+
+	err := m.locked(log, func(conn *sql.Conn) error {
+		rows, err := conn.QueryContext(context.Background(), `SELECT kind, version, applied_at FROM schema_migrations`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		appliedAt := map[Kind]map[int]time.Time{}
+		for rows.Next() {
+			var (
+				kind    Kind
+				version int
+				at      time.Time
+			)
+			if err = rows.Scan(&kind, &version, &at); err != nil {
+				return err
+			}
+			if appliedAt[kind] == nil {
+				appliedAt[kind] = map[int]time.Time{}
+			}
+			appliedAt[kind][version] = at
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		migrations, err := All()
+		if err != nil {
+			return err
+		}
+		for _, mg := range migrations {
+			st := Status{Migration: mg}
+			if at, ok := appliedAt[mg.Kind][mg.Version]; ok {
+				st.AppliedAt = &at
+			}
+			statuses = append(statuses, st)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Pending lists the embedded migrations not applied yet in the order All returns them. Unlike
+// Status it neither takes the migrations lock nor creates schema_migrations, so it can be
+// called often; a database never migrated fails with the error of the missing table.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mg := range migrations {
+		if _, ok := applied[mg.Kind][mg.Version]; !ok {
+			pending = append(pending, mg)
+		}
+	}
+
+	return pending, nil
+}
+
+// locked runs fn on a dedicated connection holding the migrations advisory lock, after making
+// sure the schema_migrations table exists.
+func (m *Migrator) locked(log *slog.Logger, fn func(conn *sql.Conn) error) error {
+	const op = "storage.migrations.locked()"
+
+	ctx := context.Background()
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		log.Error("Error to get connection for migrations", "error", err, "operation", op)
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		log.Error("Error to take migrations lock", "error", err, "operation", op)
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, advisoryLockKey); err != nil {
+			log.Error("Error to release migrations lock", "error", err, "operation", op)
+		}
+	}()
+
+	if _, err = conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		log.Error("Error to create schema_migrations table", "error", err, "operation", op)
+		return err
+	}
+
+	return fn(conn)
+}
+
+// appliedVersions reads the versions of each kind recorded in schema_migrations, through a
+// connection or the pool.
+func appliedVersions(ctx context.Context, db interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}) (map[Kind]map[int]struct{}, error) {
+	rows, err := db.QueryContext(ctx, `SELECT kind, version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[Kind]map[int]struct{}{}
+	for rows.Next() {
+		var (
+			kind    Kind
+			version int
+		)
+		if err = rows.Scan(&kind, &version); err != nil {
+			return nil, err
+		}
+		if applied[kind] == nil {
+			applied[kind] = map[int]struct{}{}
+		}
+		applied[kind][version] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
+
+// inTx executes the migration body, if any, and the bookkeeping statement in one transaction.
+func inTx(conn *sql.Conn, body string, bodyArgs []any, bookkeeping string, args ...any) error {
+	ctx := context.Background()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if body != "" {
+		if _, err = tx.ExecContext(ctx, body, bodyArgs...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,109 @@
+package migrations
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	for _, kind := range []Kind{KindSchema, KindSeed} {
+		migrations, err := Load(kind)
+		if err != nil {
+			t.Fatalf("Load(%s) error: %v", kind, err)
+		}
+
+		for i, mg := range migrations {
+			if mg.Version != i+1 {
+				t.Errorf("%s migration %d has version %d, want versions without gaps", kind, i, mg.Version)
+			}
+		}
+
+		for version := range args[kind] {
+			if version > len(migrations) || len(migrations[version-1].Args) == 0 {
+				t.Errorf("%s migration %04d has no arguments", kind, version)
+			}
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All() error: %v", err)
+	}
+
+	schemaVersion, seedVersion := 0, 0
+	for _, mg := range migrations {
+		switch mg.Kind {
+		case KindSchema:
+			if mg.Version != schemaVersion+1 {
+				t.Errorf("schema migration %04d follows schema %04d", mg.Version, schemaVersion)
+			}
+			schemaVersion = mg.Version
+		case KindSeed:
+			if mg.Version != seedVersion+1 {
+				t.Errorf("seed migration %04d follows seed %04d", mg.Version, seedVersion)
+			}
+			if seedAfter[mg.Version] != schemaVersion {
+				t.Errorf("seed migration %04d runs after schema %04d, want %04d", mg.Version, schemaVersion, seedAfter[mg.Version])
+			}
+			seedVersion = mg.Version
+		}
+	}
+
+	// Seed 0001 writes music_group, which schema 0006 drops.
+	if seedAfter[1] >= 6 {
+		t.Errorf("seed migration 0001 runs after schema %04d, it needs Library before artists", seedAfter[1])
+	}
+	if len(seedAfter) != seedVersion {
+		t.Errorf("seedAfter lists %d seed migrations, %d are embedded", len(seedAfter), seedVersion)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		file          string
+		wantVersion   int
+		wantName      string
+		wantDirection string
+		wantErr       bool
+	}{
+		{file: "0001_create_library.up.sql", wantVersion: 1, wantName: "create_library", wantDirection: "up"},
+		{file: "0012_infosong_revisions.down.sql", wantVersion: 12, wantName: "infosong_revisions", wantDirection: "down"},
+		{file: "0001_library.sideways.sql", wantErr: true},
+		{file: "library.up.sql", wantErr: true},
+		{file: "first_library.up.sql", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		version, name, direction, err := parseName(tt.file)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseName(%q) succeeded", tt.file)
+			}
+			continue
+		}
+		if err != nil || version != tt.wantVersion || name != tt.wantName || direction != tt.wantDirection {
+			t.Errorf("parseName(%q) = %d, %q, %q, %v", tt.file, version, name, direction, err)
+		}
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	songs, err := Library()
+	if err != nil {
+		t.Fatalf("Library() error: %v", err)
+	}
+	if len(songs) == 0 {
+		t.Fatal("Library() returned no songs")
+	}
+
+	for _, sg := range songs {
+		if sg.Group == "" || sg.Song == "" || sg.Text == "" {
+			t.Errorf("incomplete song %+v", sg)
+		}
+		if _, err := time.Parse(time.DateOnly, sg.ReleaseDate); err != nil {
+			t.Errorf("song %q: %v", sg.Song, err)
+		}
+	}
+}
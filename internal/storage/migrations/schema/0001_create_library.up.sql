@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS Library(
+	id serial PRIMARY KEY,
+	music_group varchar(53) NOT NULL ,
+	song varchar(50) NOT NULL ,
+	text text NOT NULL ,
+	releasedate date NOT NULL ,
+	link varchar(70) NOT NULL ,
+	UNIQUE(music_group, song)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS infosong;
+DROP TABLE IF EXISTS song;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS song(
+	id serial PRIMARY KEY,
+	music_group varchar(53) NOT NULL ,
+	song varchar(50) NOT NULL ,
+	UNIQUE(music_group, song)
+);
+
+CREATE TABLE IF NOT EXISTS infosong(
+	id serial PRIMARY KEY,
+	id_song int references song(id) ON DELETE CASCADE,
+	releasedate date ,
+	text text ,
+	link varchar(70)
+);
@@ -0,0 +1,16 @@
+DROP TRIGGER IF EXISTS song_search ON song;
+DROP TRIGGER IF EXISTS infosong_search ON infosong;
+DROP TRIGGER IF EXISTS library_search ON Library;
+
+DROP FUNCTION IF EXISTS song_search_rename();
+DROP FUNCTION IF EXISTS infosong_search_update();
+DROP FUNCTION IF EXISTS library_search_update();
+DROP FUNCTION IF EXISTS song_search_vector(regconfig, text, text, text);
+
+ALTER TABLE infosong
+	DROP COLUMN IF EXISTS search_en ,
+	DROP COLUMN IF EXISTS search_ru ;
+
+ALTER TABLE Library
+	DROP COLUMN IF EXISTS search_en ,
+	DROP COLUMN IF EXISTS search_ru ;
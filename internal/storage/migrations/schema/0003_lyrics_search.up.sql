@@ -0,0 +1,62 @@
+ALTER TABLE Library
+	ADD COLUMN IF NOT EXISTS search_en tsvector ,
+	ADD COLUMN IF NOT EXISTS search_ru tsvector ;
+
+ALTER TABLE infosong
+	ADD COLUMN IF NOT EXISTS search_en tsvector ,
+	ADD COLUMN IF NOT EXISTS search_ru tsvector ;
+
+CREATE OR REPLACE FUNCTION song_search_vector(cfg regconfig, music_group text, song text, lyrics text)
+RETURNS tsvector AS $$
+	SELECT setweight(to_tsvector(cfg, COALESCE(music_group, '')), 'A') ||
+	       setweight(to_tsvector(cfg, COALESCE(song, '')), 'A') ||
+	       setweight(to_tsvector(cfg, COALESCE(lyrics, '')), 'B');
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION library_search_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_en := song_search_vector('english', NEW.music_group, NEW.song, NEW.text);
+	NEW.search_ru := song_search_vector('russian', NEW.music_group, NEW.song, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS library_search ON Library;
+CREATE TRIGGER library_search BEFORE INSERT OR UPDATE OF music_group, song, text ON Library
+	FOR EACH ROW EXECUTE FUNCTION library_search_update();
+
+-- infosong keeps the vector, but the group and the title live in song, so both tables
+-- need a trigger: infosong recomputes on its own changes, song pokes infosong on renames.
+CREATE OR REPLACE FUNCTION infosong_search_update() RETURNS trigger AS $$
+DECLARE
+	s song%ROWTYPE;
+BEGIN
+	SELECT * INTO s FROM song WHERE id = NEW.id_song;
+	NEW.search_en := song_search_vector('english', s.music_group, s.song, NEW.text);
+	NEW.search_ru := song_search_vector('russian', s.music_group, s.song, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS infosong_search ON infosong;
+CREATE TRIGGER infosong_search BEFORE INSERT OR UPDATE OF id_song, text ON infosong
+	FOR EACH ROW EXECUTE FUNCTION infosong_search_update();
+
+CREATE OR REPLACE FUNCTION song_search_rename() RETURNS trigger AS $$
+BEGIN
+	UPDATE infosong SET text = text WHERE id_song = NEW.id;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS song_search ON song;
+CREATE TRIGGER song_search AFTER UPDATE OF music_group, song ON song
+	FOR EACH ROW EXECUTE FUNCTION song_search_rename();
+
+UPDATE Library SET text = text;
+UPDATE infosong SET text = text;
+
+CREATE INDEX IF NOT EXISTS library_search_en_idx ON Library USING gin (search_en);
+CREATE INDEX IF NOT EXISTS library_search_ru_idx ON Library USING gin (search_ru);
+CREATE INDEX IF NOT EXISTS infosong_search_en_idx ON infosong USING gin (search_en);
+CREATE INDEX IF NOT EXISTS infosong_search_ru_idx ON infosong USING gin (search_ru);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+	id serial PRIMARY KEY,
+	name varchar(100) NOT NULL ,
+	prefix varchar(16) NOT NULL ,
+	key_hash char(64) NOT NULL UNIQUE ,
+	role varchar(10) NOT NULL CHECK (role IN ('reader', 'editor', 'admin')) ,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS playlist_song;
+DROP TABLE IF EXISTS playlist;
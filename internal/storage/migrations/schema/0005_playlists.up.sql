@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS playlist(
+	id serial PRIMARY KEY,
+	name varchar(100) NOT NULL ,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS playlist_song(
+	id_playlist int NOT NULL references playlist(id) ON DELETE CASCADE,
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	position int NOT NULL ,
+	PRIMARY KEY(id_playlist, id_song)
+);
+
+CREATE INDEX IF NOT EXISTS playlist_song_position_idx ON playlist_song (id_playlist, position);
+CREATE INDEX IF NOT EXISTS playlist_song_song_idx ON playlist_song (id_song);
@@ -0,0 +1,56 @@
+DROP TRIGGER IF EXISTS artist_search ON artist;
+DROP FUNCTION IF EXISTS artist_search_rename();
+
+DROP TRIGGER IF EXISTS library_search ON Library;
+DROP TRIGGER IF EXISTS song_search ON song;
+
+ALTER TABLE Library ADD COLUMN IF NOT EXISTS music_group varchar(53);
+ALTER TABLE song ADD COLUMN IF NOT EXISTS music_group varchar(53);
+
+UPDATE Library l SET music_group = a.name FROM artist a WHERE a.id = l.id_artist;
+UPDATE song s SET music_group = a.name FROM artist a WHERE a.id = s.id_artist;
+
+ALTER TABLE Library
+	ALTER COLUMN music_group SET NOT NULL ,
+	DROP COLUMN id_artist ,
+	ADD CONSTRAINT library_music_group_song_key UNIQUE (music_group, song);
+
+ALTER TABLE song
+	ALTER COLUMN music_group SET NOT NULL ,
+	DROP COLUMN id_artist ,
+	ADD CONSTRAINT song_music_group_song_key UNIQUE (music_group, song);
+
+CREATE OR REPLACE FUNCTION library_search_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_en := song_search_vector('english', NEW.music_group, NEW.song, NEW.text);
+	NEW.search_ru := song_search_vector('russian', NEW.music_group, NEW.song, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER library_search BEFORE INSERT OR UPDATE OF music_group, song, text ON Library
+	FOR EACH ROW EXECUTE FUNCTION library_search_update();
+
+CREATE OR REPLACE FUNCTION infosong_search_update() RETURNS trigger AS $$
+DECLARE
+	s song%ROWTYPE;
+BEGIN
+	SELECT * INTO s FROM song WHERE id = NEW.id_song;
+	NEW.search_en := song_search_vector('english', s.music_group, s.song, NEW.text);
+	NEW.search_ru := song_search_vector('russian', s.music_group, s.song, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER song_search AFTER UPDATE OF music_group, song ON song
+	FOR EACH ROW EXECUTE FUNCTION song_search_rename();
+
+DROP FUNCTION IF EXISTS resolve_artist(text);
+DROP FUNCTION IF EXISTS find_artist(text);
+
+DROP TABLE IF EXISTS artist_alias;
+DROP TABLE IF EXISTS artist;
+
+DROP FUNCTION IF EXISTS artist_sort_name(text);
+DROP FUNCTION IF EXISTS artist_key(text);
+DROP FUNCTION IF EXISTS artist_clean(text);
@@ -0,0 +1,154 @@
+-- Artist names are compared by key: trimmed, inner whitespace collapsed, lower case.
+CREATE OR REPLACE FUNCTION artist_clean(group_name text) RETURNS text AS $$
+	SELECT regexp_replace(btrim(group_name), '\s+', ' ', 'g');
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION artist_key(group_name text) RETURNS text AS $$
+	SELECT lower(artist_clean(group_name));
+$$ LANGUAGE sql IMMUTABLE;
+
+-- "The Beatles" sorts as "Beatles, The".
+CREATE OR REPLACE FUNCTION artist_sort_name(group_name text) RETURNS text AS $$
+	SELECT CASE WHEN artist_clean(group_name) ~* '^the '
+		THEN substr(artist_clean(group_name), 5) || ', ' || left(artist_clean(group_name), 3)
+		ELSE artist_clean(group_name) END;
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE TABLE IF NOT EXISTS artist(
+	id serial PRIMARY KEY,
+	name varchar(53) NOT NULL ,
+	sort_name varchar(55) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS artist_name_key ON artist (artist_key(name));
+CREATE INDEX IF NOT EXISTS artist_sort_name_idx ON artist (sort_name);
+
+CREATE TABLE IF NOT EXISTS artist_alias(
+	id_artist int NOT NULL references artist(id) ON DELETE CASCADE,
+	alias varchar(53) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS artist_alias_key ON artist_alias (artist_key(alias));
+CREATE INDEX IF NOT EXISTS artist_alias_artist_idx ON artist_alias (id_artist);
+
+CREATE OR REPLACE FUNCTION find_artist(group_name text) RETURNS int AS $$
+	SELECT id FROM artist WHERE artist_key(name) = artist_key(group_name)
+	UNION ALL
+	SELECT id_artist FROM artist_alias WHERE artist_key(alias) = artist_key(group_name)
+	LIMIT 1;
+$$ LANGUAGE sql STABLE;
+
+-- resolve_artist finds an artist by name or alias and creates it if there is none.
+CREATE OR REPLACE FUNCTION resolve_artist(group_name text) RETURNS int AS $$
+DECLARE
+	artist_id int;
+BEGIN
+	artist_id := find_artist(group_name);
+	IF artist_id IS NULL THEN
+		INSERT INTO artist (name, sort_name) VALUES (artist_clean(group_name), artist_sort_name(group_name))
+			ON CONFLICT DO NOTHING
+			RETURNING id INTO artist_id;
+	END IF;
+	IF artist_id IS NULL THEN
+		-- Created concurrently.
+		artist_id := find_artist(group_name);
+	END IF;
+	RETURN artist_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- "Furure" in the seed is a typo of Future; it stays an alias so the old spelling resolves.
+INSERT INTO artist (name, sort_name) VALUES ('Future', 'Future') ON CONFLICT DO NOTHING;
+INSERT INTO artist_alias (id_artist, alias)
+	SELECT id, 'Furure' FROM artist WHERE artist_key(name) = 'future'
+	ON CONFLICT DO NOTHING;
+
+DROP TRIGGER IF EXISTS library_search ON Library;
+DROP TRIGGER IF EXISTS song_search ON song;
+
+-- The most used spelling of each artist becomes its canonical name.
+SELECT resolve_artist(music_group) FROM (
+	SELECT music_group, count(*) AS uses
+	FROM (SELECT music_group FROM Library UNION ALL SELECT music_group FROM song) spellings
+	GROUP BY music_group
+	ORDER BY uses DESC, music_group
+) ordered;
+
+ALTER TABLE Library ADD COLUMN IF NOT EXISTS id_artist int references artist(id);
+ALTER TABLE song ADD COLUMN IF NOT EXISTS id_artist int references artist(id);
+
+UPDATE Library SET id_artist = find_artist(music_group);
+UPDATE song SET id_artist = find_artist(music_group);
+
+-- Spellings that only differed in case or spacing now name the same song. The oldest row
+-- is kept; playlist entries of the others move to it before they are deleted.
+DELETE FROM Library l USING Library k
+	WHERE l.id_artist = k.id_artist AND l.song = k.song AND l.id > k.id;
+
+INSERT INTO playlist_song (id_playlist, id_song, position)
+	SELECT ps.id_playlist, k.id, min(ps.position)
+	FROM playlist_song ps
+	JOIN song d ON d.id = ps.id_song
+	JOIN LATERAL (SELECT min(m.id) AS id FROM song m WHERE m.id_artist = d.id_artist AND m.song = d.song) k ON k.id <> d.id
+	GROUP BY ps.id_playlist, k.id
+	ON CONFLICT DO NOTHING;
+
+DELETE FROM song d USING song k
+	WHERE d.id_artist = k.id_artist AND d.song = k.song AND d.id > k.id;
+
+ALTER TABLE Library
+	ALTER COLUMN id_artist SET NOT NULL ,
+	DROP COLUMN music_group ,
+	ADD CONSTRAINT library_artist_song_key UNIQUE (id_artist, song);
+
+ALTER TABLE song
+	ALTER COLUMN id_artist SET NOT NULL ,
+	DROP COLUMN music_group ,
+	ADD CONSTRAINT song_artist_song_key UNIQUE (id_artist, song);
+
+-- Search vectors take the group from artist now.
+CREATE OR REPLACE FUNCTION library_search_update() RETURNS trigger AS $$
+DECLARE
+	artist_name text;
+BEGIN
+	SELECT name INTO artist_name FROM artist WHERE id = NEW.id_artist;
+	NEW.search_en := song_search_vector('english', artist_name, NEW.song, NEW.text);
+	NEW.search_ru := song_search_vector('russian', artist_name, NEW.song, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER library_search BEFORE INSERT OR UPDATE OF id_artist, song, text ON Library
+	FOR EACH ROW EXECUTE FUNCTION library_search_update();
+
+CREATE OR REPLACE FUNCTION infosong_search_update() RETURNS trigger AS $$
+DECLARE
+	artist_name text;
+	song_name text;
+BEGIN
+	SELECT a.name, s.song INTO artist_name, song_name
+		FROM song s JOIN artist a ON a.id = s.id_artist
+		WHERE s.id = NEW.id_song;
+	NEW.search_en := song_search_vector('english', artist_name, song_name, NEW.text);
+	NEW.search_ru := song_search_vector('russian', artist_name, song_name, NEW.text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER song_search AFTER UPDATE OF id_artist, song ON song
+	FOR EACH ROW EXECUTE FUNCTION song_search_rename();
+
+CREATE OR REPLACE FUNCTION artist_search_rename() RETURNS trigger AS $$
+BEGIN
+	UPDATE Library SET text = text WHERE id_artist = NEW.id;
+	UPDATE infosong SET text = text WHERE id_song IN (SELECT id FROM song WHERE id_artist = NEW.id);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS artist_search ON artist;
+CREATE TRIGGER artist_search AFTER UPDATE OF name ON artist
+	FOR EACH ROW EXECUTE FUNCTION artist_search_rename();
+
+UPDATE Library SET text = text;
+UPDATE infosong SET text = text;
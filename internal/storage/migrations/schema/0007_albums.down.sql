@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS album_track;
+DROP TABLE IF EXISTS album;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS album(
+	id serial PRIMARY KEY,
+	id_artist int NOT NULL references artist(id),
+	title varchar(100) NOT NULL ,
+	releasedate date ,
+	album_type varchar(10) NOT NULL DEFAULT 'LP' CHECK (album_type IN ('LP', 'EP', 'single')),
+	UNIQUE(id_artist, title)
+);
+
+CREATE TABLE IF NOT EXISTS album_track(
+	id_album int NOT NULL references album(id) ON DELETE CASCADE,
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	track_number int NOT NULL CHECK (track_number > 0),
+	PRIMARY KEY(id_album, track_number),
+	UNIQUE(id_album, id_song)
+);
+
+CREATE INDEX IF NOT EXISTS album_releasedate_idx ON album (releasedate);
+CREATE INDEX IF NOT EXISTS album_track_song_idx ON album_track (id_song);
@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS genre_subtree(text);
+
+DROP TABLE IF EXISTS song_tag;
+DROP TABLE IF EXISTS song_genre;
+DROP TABLE IF EXISTS genre;
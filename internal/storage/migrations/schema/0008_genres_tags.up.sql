@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS genre(
+	id serial PRIMARY KEY,
+	name varchar(50) NOT NULL ,
+	id_parent int references genre(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS genre_name_key ON genre (lower(name));
+CREATE INDEX IF NOT EXISTS genre_parent_idx ON genre (id_parent);
+
+CREATE TABLE IF NOT EXISTS song_genre(
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	id_genre int NOT NULL references genre(id) ON DELETE CASCADE,
+	PRIMARY KEY(id_song, id_genre)
+);
+
+CREATE INDEX IF NOT EXISTS song_genre_genre_idx ON song_genre (id_genre);
+
+-- Tags are free-form; they are stored normalized to lower case.
+CREATE TABLE IF NOT EXISTS song_tag(
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	tag varchar(50) NOT NULL ,
+	PRIMARY KEY(id_song, tag)
+);
+
+CREATE INDEX IF NOT EXISTS song_tag_tag_idx ON song_tag (tag);
+
+-- genre_subtree is a genre and all of its subgenres, found by name.
+CREATE OR REPLACE FUNCTION genre_subtree(genre_name text) RETURNS SETOF int AS $$
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM genre WHERE lower(name) = lower(genre_name)
+		UNION
+		SELECT g.id FROM genre g JOIN subtree ON g.id_parent = subtree.id
+	)
+	SELECT id FROM subtree;
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rating;
+DROP TABLE IF EXISTS favorite;
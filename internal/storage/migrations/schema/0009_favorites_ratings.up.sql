@@ -0,0 +1,20 @@
+-- Favorites and ratings belong to a user: the API key that made the request, or the user a
+-- trusted proxy names in X-User-Id.
+CREATE TABLE IF NOT EXISTS favorite(
+	user_id varchar(100) NOT NULL ,
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY(user_id, id_song)
+);
+
+CREATE INDEX IF NOT EXISTS favorite_song_idx ON favorite (id_song);
+
+CREATE TABLE IF NOT EXISTS rating(
+	user_id varchar(100) NOT NULL ,
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	stars smallint NOT NULL CHECK (stars BETWEEN 1 AND 5),
+	rated_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY(user_id, id_song)
+);
+
+CREATE INDEX IF NOT EXISTS rating_song_idx ON rating (id_song);
@@ -0,0 +1,10 @@
+-- play is written in batches and read by time window, so it is indexed by play time.
+CREATE TABLE IF NOT EXISTS play(
+	id bigserial PRIMARY KEY,
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	played_at timestamptz NOT NULL ,
+	duration int CHECK (duration >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS play_played_at_idx ON play (played_at);
+CREATE INDEX IF NOT EXISTS play_song_idx ON play (id_song, played_at);
@@ -0,0 +1,3 @@
+DELETE FROM song WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS song_deleted_at_idx;
+ALTER TABLE song DROP COLUMN IF EXISTS deleted_at;
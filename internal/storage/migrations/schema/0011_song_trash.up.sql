@@ -0,0 +1,5 @@
+-- A deleted song stays in song with deleted_at set until it is restored or purged, so its
+-- info and everything referencing it survive a mistaken delete.
+ALTER TABLE song ADD COLUMN IF NOT EXISTS deleted_at timestamptz ;
+
+CREATE INDEX IF NOT EXISTS song_deleted_at_idx ON song (deleted_at) WHERE deleted_at IS NOT NULL;
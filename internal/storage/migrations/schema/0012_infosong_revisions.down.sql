@@ -0,0 +1 @@
+DROP TABLE IF EXISTS infosong_revision;
@@ -0,0 +1,15 @@
+-- Every change to the info of a song is kept as a revision: the info as it was after the
+-- change, the fields that changed and who changed them. Revision 1 of a song is its info
+-- before the first change, recorded with that change.
+CREATE TABLE IF NOT EXISTS infosong_revision(
+	id_song int NOT NULL references song(id) ON DELETE CASCADE,
+	revision int NOT NULL ,
+	releasedate date ,
+	text text ,
+	link varchar(70) ,
+	changed_fields text[] NOT NULL DEFAULT '{}',
+	editor varchar(100) ,
+	rollback_of int ,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY(id_song, revision)
+);
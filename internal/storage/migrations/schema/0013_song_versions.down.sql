@@ -0,0 +1,2 @@
+ALTER TABLE infosong_revision DROP COLUMN IF EXISTS version;
+ALTER TABLE song DROP COLUMN IF EXISTS version;
@@ -0,0 +1,5 @@
+-- version counts the changes to a song, its info or the name of its artist; the API hands
+-- it out as the ETag of the song. A revision remembers the version it left the song at.
+ALTER TABLE song ADD COLUMN IF NOT EXISTS version int NOT NULL DEFAULT 1;
+
+ALTER TABLE infosong_revision ADD COLUMN IF NOT EXISTS version int ;
@@ -363,4 +363,4 @@ VALUES (
            '2011-05-13',
            'https://genius.com/Nicki-minaj-super-bass-lyrics'
        )
-    ON CONFLICT (music_group, song) DO NOTHING;;
+    ON CONFLICT (music_group, song) DO NOTHING;
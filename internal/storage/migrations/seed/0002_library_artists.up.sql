@@ -0,0 +1,4 @@
+INSERT INTO Library (id_artist, song, text, releasedate, link)
+SELECT resolve_artist(music_group), song, text, releasedate, link
+FROM json_to_recordset($1::json) AS seed(music_group text, song text, text text, releasedate date, link text)
+    ON CONFLICT (id_artist, song) DO NOTHING;
@@ -0,0 +1,370 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AlbumLP     = "LP"
+	AlbumEP     = "EP"
+	AlbumSingle = "single"
+)
+
+func ValidAlbumType(albumType string) bool {
+	switch albumType {
+	case AlbumLP, AlbumEP, AlbumSingle:
+		return true
+	}
+	return false
+}
+
+type Album struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	ArtistID    int        `json:"artistId"`
+	Artist      string     `json:"artist"`
+	ReleaseDate *time.Time `json:"releaseDate"`
+	Type        string     `json:"type"`
+	TrackCount  int        `json:"trackCount"`
+}
+
+// Track is a library song on an album. Numbers count from 1; deleting a song from the
+// library leaves a gap rather than renumbering the album.
+type Track struct {
+	Number int   `json:"number"`
+	Songs  Songs `json:"songs"`
+}
+
+type AlbumDetails struct {
+	Album
+	Tracks []Track `json:"tracks"`
+}
+
+// NewAlbum describes an album to create. Artist is resolved by name or alias like the
+// group of a song, and Tracks lists library song IDs in track order.
+type NewAlbum struct {
+	Title       string     `json:"title"`
+	Artist      string     `json:"artist"`
+	ReleaseDate *time.Time `json:"releaseDate"`
+	Type        string     `json:"type"`
+	Tracks      []int      `json:"tracks"`
+}
+
+// AlbumQuery filters and pages the album listing. Albums come newest first.
+type AlbumQuery struct {
+	ArtistID int
+	Type     string
+	Limit    int
+	Offset   int
+}
+
+type AlbumPage struct {
+	Items  []Album `json:"items"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+var (
+	ErrAlbumNotFound = errors.New("album not found")
+	ErrAlbumExists   = errors.New("the artist already has an album with this title")
+)
+
+func (q *AlbumQuery) Normalize() error {
+	if q.Type != "" && !ValidAlbumType(q.Type) {
+		return invalidQuery("type", fmt.Sprintf("unknown album type %q", q.Type))
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.Offset < 0 {
+		return invalidQuery("offset", "offset must not be negative")
+	}
+	return nil
+}
+
+// albumReleaseDate is the release date of the earliest album a song of the user library is
+// on. Library listings join it when asked to fall back to album release dates.
+const albumReleaseDate = ` LEFT JOIN LATERAL (SELECT min(al.releasedate) AS releasedate
+				FROM album_track t JOIN album al ON al.id = t.id_album
+				WHERE t.id_song = s.id) ad ON true`
+
+const albumSelect = `SELECT al.id, al.title, al.id_artist, a.name, al.releasedate, al.album_type,
+			(SELECT count(*) FROM album_track t JOIN song s ON s.id = t.id_song AND s.deleted_at IS NULL WHERE t.id_album = al.id)
+			FROM album al JOIN artist a ON a.id = al.id_artist`
+
+func scanAlbum(row interface{ Scan(...any) error }) (Album, error) {
+	var al Album
+	err := row.Scan(&al.ID, &al.Title, &al.ArtistID, &al.Artist, &al.ReleaseDate, &al.Type, &al.TrackCount)
+	return al, err
+}
+
+func (s *Storage) CreateAlbum(ctx context.Context, album NewAlbum, log *slog.Logger) (AlbumDetails, error) {
+	const op = "storage.postgres.CreateAlbum()"
+
+	ctx, span := startSpan(ctx, "CreateAlbum")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO album (id_artist, title, releasedate, album_type)
+				VALUES (resolve_artist($1), $2, $3, $4) RETURNING id`
+
+	var id int
+
+	err = tx.QueryRowContext(ctx, query, album.Artist, album.Title, album.ReleaseDate, album.Type).Scan(&id)
+	if err != nil {
+		if pqCode(err) == codeUniqueViolation {
+			return AlbumDetails{}, ErrAlbumExists
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	if err = insertTracks(ctx, tx, id, album.Tracks); err != nil {
+		if !errors.Is(err, ErrSongNotFound) {
+			log.Error("Error to insert tracks", "error", err, "operation", op)
+		}
+		return AlbumDetails{}, err
+	}
+
+	details, err := getAlbum(ctx, tx, id)
+	if err != nil {
+		log.Error("Error to get album", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	return details, nil
+}
+
+func (s *Storage) ListAlbums(ctx context.Context, q AlbumQuery, log *slog.Logger) (AlbumPage, error) {
+	const op = "storage.postgres.ListAlbums()"
+
+	ctx, span := startSpan(ctx, "ListAlbums")
+	defer span.End()
+
+	var (
+		conds []string
+		args  []any
+	)
+
+	if q.ArtistID != 0 {
+		args = append(args, q.ArtistID)
+		conds = append(conds, "al.id_artist = $"+strconv.Itoa(len(args)))
+	}
+	if q.Type != "" {
+		args = append(args, q.Type)
+		conds = append(conds, "al.album_type = $"+strconv.Itoa(len(args)))
+	}
+
+	p := AlbumPage{Items: []Album{}, Limit: q.Limit, Offset: q.Offset}
+
+	err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM album al`+where(conds), args...).Scan(&p.Total)
+	if err != nil {
+		log.Error("Error to count albums", "error", err, "operation", op)
+		return AlbumPage{}, err
+	}
+
+	args = append(args, q.Limit, q.Offset)
+	query := albumSelect + where(conds) +
+		fmt.Sprintf(" ORDER BY al.releasedate DESC NULLS LAST, al.id LIMIT $%d OFFSET $%d;", len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error("Error to get albums", "error", err, "operation", op)
+		return AlbumPage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		al, err := scanAlbum(rows)
+		if err != nil {
+			log.Error("Error to get albums", "error", err, "operation", op)
+			return AlbumPage{}, err
+		}
+		p.Items = append(p.Items, al)
+	}
+
+	return p, rows.Err()
+}
+
+func (s *Storage) GetAlbum(ctx context.Context, id int, log *slog.Logger) (AlbumDetails, error) {
+	const op = "storage.postgres.GetAlbum()"
+
+	ctx, span := startSpan(ctx, "GetAlbum")
+	defer span.End()
+
+	details, err := getAlbum(ctx, s.db, id)
+	if err != nil && !errors.Is(err, ErrAlbumNotFound) {
+		log.Error("Error to get album", "error", err, "operation", op)
+	}
+
+	return details, err
+}
+
+// SetAlbumTracks replaces the track listing of an album with songIDs in track order.
+func (s *Storage) SetAlbumTracks(ctx context.Context, id int, songIDs []int, log *slog.Logger) (AlbumDetails, error) {
+	const op = "storage.postgres.SetAlbumTracks()"
+
+	ctx, span := startSpan(ctx, "SetAlbumTracks")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM album WHERE id = $1 FOR UPDATE;`, id).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AlbumDetails{}, ErrAlbumNotFound
+		}
+		log.Error("Error to lock album", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM album_track WHERE id_album = $1;`, id); err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	if err = insertTracks(ctx, tx, id, songIDs); err != nil {
+		if !errors.Is(err, ErrSongNotFound) {
+			log.Error("Error to insert tracks", "error", err, "operation", op)
+		}
+		return AlbumDetails{}, err
+	}
+
+	details, err := getAlbum(ctx, tx, id)
+	if err != nil {
+		log.Error("Error to get album", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return AlbumDetails{}, err
+	}
+
+	return details, nil
+}
+
+func (s *Storage) DeleteAlbum(ctx context.Context, id int, log *slog.Logger) error {
+	const op = "storage.postgres.DeleteAlbum()"
+
+	ctx, span := startSpan(ctx, "DeleteAlbum")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM album WHERE id = $1;`, id)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrAlbumNotFound
+	}
+
+	return nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func getAlbum(ctx context.Context, db querier, id int) (AlbumDetails, error) {
+	al, err := scanAlbum(db.QueryRowContext(ctx, albumSelect+` WHERE al.id = $1;`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return AlbumDetails{}, ErrAlbumNotFound
+		}
+		return AlbumDetails{}, err
+	}
+
+	query := `SELECT t.track_number, s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, '')
+				FROM album_track t
+				JOIN song s ON s.id = t.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE t.id_album = $1
+				ORDER BY t.track_number;`
+
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return AlbumDetails{}, err
+	}
+	defer rows.Close()
+
+	details := AlbumDetails{Album: al, Tracks: []Track{}}
+
+	for rows.Next() {
+		var t Track
+		err = rows.Scan(&t.Number,
+			&t.Songs.ID,
+			&t.Songs.Song.Group,
+			&t.Songs.Song.Name,
+			&t.Songs.InfoSong.Text,
+			&t.Songs.InfoSong.ReleaseDate,
+			&t.Songs.InfoSong.Link)
+		if err != nil {
+			return AlbumDetails{}, err
+		}
+		details.Tracks = append(details.Tracks, t)
+	}
+
+	return details, rows.Err()
+}
+
+func insertTracks(ctx context.Context, tx *sql.Tx, id int, songIDs []int) error {
+	if len(songIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(songIDs))
+	for i, songID := range songIDs {
+		ids[i] = int64(songID)
+	}
+
+	query := `INSERT INTO album_track (id_album, id_song, track_number)
+				SELECT $1, t.id_song, t.number FROM unnest($2::int[]) WITH ORDINALITY AS t(id_song, number)
+				WHERE EXISTS (SELECT 1 FROM song s WHERE s.id = t.id_song AND s.deleted_at IS NULL);`
+
+	res, err := tx.ExecContext(ctx, query, id, pq.Array(ids))
+	if err != nil {
+		if pqCode(err) == codeForeignKeyViolation {
+			return ErrSongNotFound
+		}
+		return err
+	}
+
+	// Tracks of songs that are not in the library were left out.
+	if n, err := res.RowsAffected(); err == nil && n != int64(len(ids)) {
+		return ErrSongNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Artist is a group or performer. Songs reference it by ID, so a rename applies to all of
+// them; its former names stay as aliases and still resolve to it.
+type Artist struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	SortName  string   `json:"sortName"`
+	Aliases   []string `json:"aliases"`
+	SongCount int      `json:"songCount"`
+}
+
+var (
+	ErrArtistNotFound = errors.New("artist not found")
+	ErrArtistExists   = errors.New("another artist already has this name or alias")
+)
+
+// CleanArtistName trims a name and collapses its inner whitespace, like artist_clean() in SQL.
+func CleanArtistName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// ArtistKey is what artist names and aliases are compared by, like artist_key() in SQL.
+func ArtistKey(name string) string {
+	return strings.ToLower(CleanArtistName(name))
+}
+
+// SortName moves a leading "The" to the end, like artist_sort_name() in SQL.
+func SortName(name string) string {
+	name = CleanArtistName(name)
+	if len(name) > 4 && strings.EqualFold(name[:4], "the ") {
+		return name[4:] + ", " + name[:3]
+	}
+	return name
+}
+
+const artistSelect = `SELECT a.id, a.name, a.sort_name,
+			COALESCE((SELECT array_agg(al.alias ORDER BY al.alias) FROM artist_alias al WHERE al.id_artist = a.id), '{}'),
+			(SELECT count(*) FROM song s WHERE s.id_artist = a.id AND s.deleted_at IS NULL)
+			FROM artist a`
+
+func scanArtist(row interface{ Scan(...any) error }) (Artist, error) {
+	var a Artist
+	err := row.Scan(&a.ID, &a.Name, &a.SortName, pq.Array(&a.Aliases), &a.SongCount)
+	if a.Aliases == nil {
+		a.Aliases = []string{}
+	}
+	return a, err
+}
+
+func (s *Storage) ListArtists(ctx context.Context, log *slog.Logger) ([]Artist, error) {
+	const op = "storage.postgres.ListArtists()"
+
+	ctx, span := startSpan(ctx, "ListArtists")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, artistSelect+` ORDER BY a.sort_name, a.id;`)
+	if err != nil {
+		log.Error("Error to get artists", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []Artist{}
+
+	for rows.Next() {
+		a, err := scanArtist(rows)
+		if err != nil {
+			log.Error("Error to get artists", "error", err, "operation", op)
+			return nil, err
+		}
+		artists = append(artists, a)
+	}
+
+	return artists, rows.Err()
+}
+
+func (s *Storage) GetArtist(ctx context.Context, id int, log *slog.Logger) (Artist, error) {
+	const op = "storage.postgres.GetArtist()"
+
+	ctx, span := startSpan(ctx, "GetArtist")
+	defer span.End()
+
+	a, err := scanArtist(s.db.QueryRowContext(ctx, artistSelect+` WHERE a.id = $1;`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Artist{}, ErrArtistNotFound
+		}
+		log.Error("Error to get artist", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	return a, nil
+}
+
+// RenameArtist changes the canonical name of an artist and keeps the old one as an alias.
+// An empty sortName is derived from the new name.
+func (s *Storage) RenameArtist(ctx context.Context, id int, name, sortName string, log *slog.Logger) (Artist, error) {
+	const op = "storage.postgres.RenameArtist()"
+
+	ctx, span := startSpan(ctx, "RenameArtist")
+	defer span.End()
+
+	name = CleanArtistName(name)
+	if sortName == "" {
+		sortName = SortName(name)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return Artist{}, err
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM artist WHERE id = $1 FOR UPDATE;`, id).Scan(&oldName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Artist{}, ErrArtistNotFound
+		}
+		log.Error("Error to lock artist", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	var owner sql.NullInt64
+	if err = tx.QueryRowContext(ctx, `SELECT find_artist($1);`, name).Scan(&owner); err != nil {
+		log.Error("Error to find artist", "error", err, "operation", op)
+		return Artist{}, err
+	}
+	if owner.Valid && int(owner.Int64) != id {
+		return Artist{}, ErrArtistExists
+	}
+
+	// The new name may have been an alias, and the old one becomes one.
+	_, err = tx.ExecContext(ctx, `DELETE FROM artist_alias WHERE id_artist = $1 AND artist_key(alias) = artist_key($2);`, id, name)
+	if err != nil {
+		log.Error("Error to delete alias", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	if ArtistKey(oldName) != ArtistKey(name) {
+		_, err = tx.ExecContext(ctx, `INSERT INTO artist_alias (id_artist, alias) VALUES ($1, $2);`, id, oldName)
+		if err != nil {
+			log.Error("Error to insert alias", "error", err, "operation", op)
+			return Artist{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE artist SET name = $1, sort_name = $2 WHERE id = $3;`, name, sortName, id)
+	if err != nil {
+		if pqCode(err) == codeUniqueViolation {
+			return Artist{}, ErrArtistExists
+		}
+		log.Error("Error to update", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	// The group of every song of the artist changes with its name.
+	if _, err = tx.ExecContext(ctx, `UPDATE song SET version = version + 1 WHERE id_artist = $1;`, id); err != nil {
+		log.Error("Error to update", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	a, err := scanArtist(tx.QueryRowContext(ctx, artistSelect+` WHERE a.id = $1;`, id))
+	if err != nil {
+		log.Error("Error to get artist", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return Artist{}, err
+	}
+
+	return a, nil
+}
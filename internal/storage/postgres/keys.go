@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// APIKey describes a key without its secret; only the SHA-256 hash of a key is stored.
+type APIKey struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var ErrKeyNotFound = errors.New("api key not found")
+
+func (s *Storage) CreateKey(ctx context.Context, name, role, prefix, hash string, log *slog.Logger) (APIKey, error) {
+	const op = "storage.postgres.CreateKey()"
+
+	ctx, span := startSpan(ctx, "CreateKey")
+	defer span.End()
+
+	query := `INSERT INTO api_keys (name, role, prefix, key_hash) VALUES ($1, $2, $3, $4)
+				RETURNING id, name, prefix, role, created_at`
+
+	var key APIKey
+
+	err := s.db.QueryRowContext(ctx, query, name, role, prefix, hash).Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedAt)
+	if err != nil {
+		log.Error("Error to insert", "error", err, "operation", op)
+		return APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (s *Storage) GetKeyByHash(ctx context.Context, hash string, log *slog.Logger) (APIKey, error) {
+	const op = "storage.postgres.GetKeyByHash()"
+
+	ctx, span := startSpan(ctx, "GetKeyByHash")
+	defer span.End()
+
+	query := `SELECT id, name, prefix, role, created_at FROM api_keys WHERE key_hash = $1;`
+
+	var key APIKey
+
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return APIKey{}, ErrKeyNotFound
+		}
+		log.Error("Error to get api key", "error", err, "operation", op)
+		return APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (s *Storage) ListKeys(ctx context.Context, log *slog.Logger) ([]APIKey, error) {
+	const op = "storage.postgres.ListKeys()"
+
+	ctx, span := startSpan(ctx, "ListKeys")
+	defer span.End()
+
+	query := `SELECT id, name, prefix, role, created_at FROM api_keys ORDER BY id;`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Error("Error to get api keys", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+		if err = rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Role, &key.CreatedAt); err != nil {
+			log.Error("Error to get api keys", "error", err, "operation", op)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Storage) DeleteKey(ctx context.Context, id int, log *slog.Logger) error {
+	const op = "storage.postgres.DeleteKey()"
+
+	ctx, span := startSpan(ctx, "DeleteKey")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1;`, id)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
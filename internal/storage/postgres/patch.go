@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// SongPatch is a partial change to a song. Group and Name replace the artist and the title
+// of the song unless nil. Only the info fields listed in Fields are set from Info; a nil
+// release date clears the date and an empty text or link is stored as an empty string,
+// which is what a merge patch null for them means. Reads turn a NULL text or link into an
+// empty string as well, so the two cannot be told apart.
+type SongPatch struct {
+	Group  *string
+	Name   *string
+	Info   InfoSong
+	Fields []string
+}
+
+// Apply returns info with the fields the patch sets replaced.
+func (p SongPatch) Apply(info InfoSong) InfoSong {
+	for _, field := range p.Fields {
+		switch field {
+		case FieldReleaseDate:
+			info.ReleaseDate = p.Info.ReleaseDate
+		case FieldText:
+			info.Text = p.Info.Text
+		case FieldLink:
+			info.Link = p.Info.Link
+		}
+	}
+	return info
+}
+
+// PatchSong applies a patch to a library song made by editor. A change to the info is
+// recorded as a revision; a new artist or title is not, but like the info it moves the song
+// to the next version. A version other than 0 must match the one of the song. It returns
+// the song and its version after the patch.
+func (s *Storage) PatchSong(ctx context.Context, id int, patch SongPatch, editor string, ifVersion int, log *slog.Logger) (Songs, int, error) {
+	const op = "storage.postgres.PatchSong()"
+
+	ctx, span := startSpan(ctx, "PatchSong")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return Songs{}, 0, err
+	}
+	defer tx.Rollback()
+
+	before, version, err := lockInfo(ctx, tx, id, ifVersion)
+	if err != nil {
+		if !errors.Is(err, ErrSongNotFound) && !errors.Is(err, ErrVersionMismatch) {
+			log.Error("Error to get song info", "error", err, "operation", op)
+		}
+		return Songs{}, 0, err
+	}
+
+	song := Songs{ID: id, InfoSong: patch.Apply(before)}
+
+	var artistID int
+	query := `SELECT s.id_artist, a.name, s.song FROM song s JOIN artist a ON a.id = s.id_artist WHERE s.id = $1;`
+	if err = tx.QueryRowContext(ctx, query, id).Scan(&artistID, &song.Song.Group, &song.Song.Name); err != nil {
+		log.Error("Error to get song", "error", err, "operation", op)
+		return Songs{}, 0, err
+	}
+
+	renamed := false
+	if patch.Group != nil || patch.Name != nil {
+		newArtistID, name := artistID, song.Song.Name
+		if patch.Group != nil {
+			if err = tx.QueryRowContext(ctx, `SELECT resolve_artist($1)`, *patch.Group).Scan(&newArtistID); err != nil {
+				log.Error("Error to resolve artist", "error", err, "operation", op)
+				return Songs{}, 0, err
+			}
+		}
+		if patch.Name != nil {
+			name = *patch.Name
+		}
+
+		if newArtistID != artistID || name != song.Song.Name {
+			query = `UPDATE song SET id_artist = $1, song = $2 WHERE id = $3
+						RETURNING (SELECT name FROM artist WHERE id = $1);`
+
+			err = tx.QueryRowContext(ctx, query, newArtistID, name, id).Scan(&song.Song.Group)
+			if err != nil {
+				if pqCode(err) == codeUniqueViolation {
+					tx.Rollback()
+					return Songs{}, 0, s.existingSong(ctx, newArtistID, name, op, log)
+				}
+				log.Error("Error to rename song", "error", err, "operation", op)
+				return Songs{}, 0, err
+			}
+			song.Song.Name = name
+			renamed = true
+		}
+	}
+
+	rev, err := writeInfo(ctx, tx, id, version, before, song.InfoSong, editor, nil)
+	if err != nil {
+		log.Error("Error to update", "error", err, "operation", op)
+		return Songs{}, 0, err
+	}
+
+	switch {
+	case rev.Revision != 0:
+		version = rev.Version
+	case renamed:
+		if _, err = tx.ExecContext(ctx, `UPDATE song SET version = version + 1 WHERE id = $1;`, id); err != nil {
+			log.Error("Error to update version", "error", err, "operation", op)
+			return Songs{}, 0, err
+		}
+		version++
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return Songs{}, 0, err
+	}
+
+	return song, version, nil
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSongPatchApply(t *testing.T) {
+	date := time.Date(2006, 9, 26, 0, 0, 0, 0, time.UTC)
+	newDate := time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC)
+	info := InfoSong{ReleaseDate: &date, Text: "Shady", Link: "https://genius.com"}
+
+	tests := []struct {
+		name  string
+		patch SongPatch
+		want  InfoSong
+	}{
+		{
+			name:  "no fields",
+			patch: SongPatch{Info: InfoSong{Text: "ignored"}},
+			want:  info,
+		},
+		{
+			name:  "replace the text only",
+			patch: SongPatch{Info: InfoSong{Text: "Konvict", Link: "ignored"}, Fields: []string{FieldText}},
+			want:  InfoSong{ReleaseDate: &date, Text: "Konvict", Link: "https://genius.com"},
+		},
+		{
+			name:  "replace the date",
+			patch: SongPatch{Info: InfoSong{ReleaseDate: &newDate}, Fields: []string{FieldReleaseDate}},
+			want:  InfoSong{ReleaseDate: &newDate, Text: "Shady", Link: "https://genius.com"},
+		},
+		{
+			name:  "null clears to an empty string and no date",
+			patch: SongPatch{Fields: []string{FieldReleaseDate, FieldText, FieldLink}},
+			want:  InfoSong{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.patch.Apply(info); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Playlist struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	SongCount int       `json:"songCount"`
+}
+
+// PlaylistEntry is a song in a playlist. Positions count from 1 in playlist order.
+type PlaylistEntry struct {
+	Position int   `json:"position"`
+	Songs    Songs `json:"songs"`
+}
+
+type PlaylistDetails struct {
+	Playlist
+	Entries []PlaylistEntry `json:"entries"`
+}
+
+var (
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	ErrSongNotFound     = errors.New("song not found")
+	ErrSongInPlaylist   = errors.New("song is already in the playlist")
+	ErrSongNotInList    = errors.New("song is not in the playlist")
+)
+
+// PostgreSQL error codes the storage maps to its own errors.
+const (
+	codeUniqueViolation     = "23505"
+	codeForeignKeyViolation = "23503"
+)
+
+func pqCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+// MoveSong returns order with songID moved to the 1-based position, or inserted there if it
+// was not in order yet. Positions past the end append.
+func MoveSong(order []int, songID, position int) []int {
+	order = slices.DeleteFunc(slices.Clone(order), func(id int) bool { return id == songID })
+
+	index := position - 1
+	if index < 0 || index > len(order) {
+		index = len(order)
+	}
+
+	return slices.Insert(order, index, songID)
+}
+
+// KeepHidden returns the order of a whole playlist after its visible songs were put in the
+// order visible. Entries of songs visible does not report, trashed ones, stay right after
+// the visible song they followed, or at the front when none did; after a removed song they
+// follow the one before it.
+func KeepHidden(entries []int, isVisible func(songID int) bool, visible []int) []int {
+	kept := make(map[int]bool, len(visible))
+	for _, songID := range visible {
+		kept[songID] = true
+	}
+
+	// Hidden entries by the visible song they follow, 0 for the front.
+	hidden := make(map[int][]int)
+	after := 0
+	for _, songID := range entries {
+		switch {
+		case isVisible(songID):
+			if kept[songID] {
+				after = songID
+			}
+		case !kept[songID]:
+			hidden[after] = append(hidden[after], songID)
+		}
+	}
+
+	order := make([]int, 0, len(visible)+len(entries))
+	order = append(order, hidden[0]...)
+	for _, songID := range visible {
+		order = append(order, songID)
+		order = append(order, hidden[songID]...)
+	}
+
+	return order
+}
+
+func (s *Storage) CreatePlaylist(ctx context.Context, name string, log *slog.Logger) (Playlist, error) {
+	const op = "storage.postgres.CreatePlaylist()"
+
+	ctx, span := startSpan(ctx, "CreatePlaylist")
+	defer span.End()
+
+	query := `INSERT INTO playlist (name) VALUES ($1) RETURNING id, name, created_at`
+
+	var p Playlist
+
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&p.ID, &p.Name, &p.CreatedAt)
+	if err != nil {
+		log.Error("Error to insert", "error", err, "operation", op)
+		return Playlist{}, err
+	}
+
+	return p, nil
+}
+
+func (s *Storage) ListPlaylists(ctx context.Context, log *slog.Logger) ([]Playlist, error) {
+	const op = "storage.postgres.ListPlaylists()"
+
+	ctx, span := startSpan(ctx, "ListPlaylists")
+	defer span.End()
+
+	query := `SELECT p.id, p.name, p.created_at, count(ps.id_song)
+				FROM playlist p
+				LEFT JOIN playlist_song ps ON ps.id_playlist = p.id
+					AND EXISTS (SELECT 1 FROM song s WHERE s.id = ps.id_song AND s.deleted_at IS NULL)
+				GROUP BY p.id
+				ORDER BY p.id;`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Error("Error to get playlists", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	playlists := []Playlist{}
+
+	for rows.Next() {
+		var p Playlist
+		if err = rows.Scan(&p.ID, &p.Name, &p.CreatedAt, &p.SongCount); err != nil {
+			log.Error("Error to get playlists", "error", err, "operation", op)
+			return nil, err
+		}
+		playlists = append(playlists, p)
+	}
+
+	return playlists, rows.Err()
+}
+
+func (s *Storage) GetPlaylist(ctx context.Context, id int, log *slog.Logger) (PlaylistDetails, error) {
+	const op = "storage.postgres.GetPlaylist()"
+
+	ctx, span := startSpan(ctx, "GetPlaylist")
+	defer span.End()
+
+	var p PlaylistDetails
+
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM playlist WHERE id = $1;`, id).Scan(&p.ID, &p.Name, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PlaylistDetails{}, ErrPlaylistNotFound
+		}
+		log.Error("Error to get playlist", "error", err, "operation", op)
+		return PlaylistDetails{}, err
+	}
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, '')
+				FROM playlist_song ps
+				JOIN song s ON s.id = ps.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE ps.id_playlist = $1
+				ORDER BY ps.position, ps.id_song;`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		log.Error("Error to get playlist songs", "error", err, "operation", op)
+		return PlaylistDetails{}, err
+	}
+	defer rows.Close()
+
+	p.Entries = []PlaylistEntry{}
+
+	for rows.Next() {
+		e := PlaylistEntry{Position: len(p.Entries) + 1}
+		err = rows.Scan(&e.Songs.ID,
+			&e.Songs.Song.Group,
+			&e.Songs.Song.Name,
+			&e.Songs.InfoSong.Text,
+			&e.Songs.InfoSong.ReleaseDate,
+			&e.Songs.InfoSong.Link)
+		if err != nil {
+			log.Error("Error to get playlist songs", "error", err, "operation", op)
+			return PlaylistDetails{}, err
+		}
+		p.Entries = append(p.Entries, e)
+	}
+	p.SongCount = len(p.Entries)
+
+	return p, rows.Err()
+}
+
+func (s *Storage) RenamePlaylist(ctx context.Context, id int, name string, log *slog.Logger) (Playlist, error) {
+	const op = "storage.postgres.RenamePlaylist()"
+
+	ctx, span := startSpan(ctx, "RenamePlaylist")
+	defer span.End()
+
+	query := `UPDATE playlist SET name = $1 WHERE id = $2 RETURNING id, name, created_at`
+
+	var p Playlist
+
+	err := s.db.QueryRowContext(ctx, query, name, id).Scan(&p.ID, &p.Name, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Playlist{}, ErrPlaylistNotFound
+		}
+		log.Error("Error to update", "error", err, "operation", op)
+		return Playlist{}, err
+	}
+
+	return p, nil
+}
+
+func (s *Storage) DeletePlaylist(ctx context.Context, id int, log *slog.Logger) error {
+	const op = "storage.postgres.DeletePlaylist()"
+
+	ctx, span := startSpan(ctx, "DeletePlaylist")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM playlist WHERE id = $1;`, id)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrPlaylistNotFound
+	}
+
+	return nil
+}
+
+// AddToPlaylist puts a song at the 1-based position, or at the end when position is 0.
+func (s *Storage) AddToPlaylist(ctx context.Context, id, songID, position int, log *slog.Logger) error {
+	const op = "storage.postgres.AddToPlaylist()"
+
+	ctx, span := startSpan(ctx, "AddToPlaylist")
+	defer span.End()
+
+	return s.reorderPlaylist(ctx, id, op, log, func(order []int) ([]int, error) {
+		if slices.Contains(order, songID) {
+			return nil, ErrSongInPlaylist
+		}
+		return MoveSong(order, songID, position), nil
+	})
+}
+
+func (s *Storage) RemoveFromPlaylist(ctx context.Context, id, songID int, log *slog.Logger) error {
+	const op = "storage.postgres.RemoveFromPlaylist()"
+
+	ctx, span := startSpan(ctx, "RemoveFromPlaylist")
+	defer span.End()
+
+	return s.reorderPlaylist(ctx, id, op, log, func(order []int) ([]int, error) {
+		if !slices.Contains(order, songID) {
+			return nil, ErrSongNotInList
+		}
+		return slices.DeleteFunc(order, func(sid int) bool { return sid == songID }), nil
+	})
+}
+
+// MovePlaylistSong moves a song already in the playlist to the 1-based position.
+func (s *Storage) MovePlaylistSong(ctx context.Context, id, songID, position int, log *slog.Logger) error {
+	const op = "storage.postgres.MovePlaylistSong()"
+
+	ctx, span := startSpan(ctx, "MovePlaylistSong")
+	defer span.End()
+
+	return s.reorderPlaylist(ctx, id, op, log, func(order []int) ([]int, error) {
+		if !slices.Contains(order, songID) {
+			return nil, ErrSongNotInList
+		}
+		return MoveSong(order, songID, position), nil
+	})
+}
+
+// reorderPlaylist locks the playlist, lets change compute the new order of its library songs
+// from the current one and writes the playlist back with positions 1..n, all in one
+// transaction. Positions change sees count library songs only, as GetPlaylist numbers them;
+// entries of trashed songs keep their place among them.
+func (s *Storage) reorderPlaylist(ctx context.Context, id int, op string, log *slog.Logger, change func(order []int) ([]int, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM playlist WHERE id = $1 FOR UPDATE;`, id).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPlaylistNotFound
+		}
+		log.Error("Error to lock playlist", "error", err, "operation", op)
+		return err
+	}
+
+	query := `SELECT ps.id_song, s.deleted_at IS NULL
+				FROM playlist_song ps
+				JOIN song s ON s.id = ps.id_song
+				WHERE ps.id_playlist = $1
+				ORDER BY ps.position, ps.id_song;`
+
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		log.Error("Error to get playlist songs", "error", err, "operation", op)
+		return err
+	}
+
+	var entries, order []int
+	live := make(map[int]bool)
+	for rows.Next() {
+		var (
+			songID    int
+			inLibrary bool
+		)
+		if err = rows.Scan(&songID, &inLibrary); err != nil {
+			rows.Close()
+			log.Error("Error to get playlist songs", "error", err, "operation", op)
+			return err
+		}
+		entries = append(entries, songID)
+		if inLibrary {
+			live[songID] = true
+			order = append(order, songID)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if order, err = change(order); err != nil {
+		return err
+	}
+
+	// Songs change added must be in the library; they are locked so they stay there until
+	// the transaction ends.
+	var added []int64
+	for _, songID := range order {
+		if !live[songID] {
+			added = append(added, int64(songID))
+		}
+	}
+	if len(added) > 0 {
+		var n int
+		query = `SELECT count(*) FROM (SELECT id FROM song WHERE id = ANY($1) AND deleted_at IS NULL FOR SHARE) live;`
+		if err = tx.QueryRowContext(ctx, query, pq.Array(added)).Scan(&n); err != nil {
+			log.Error("Error to get songs", "error", err, "operation", op)
+			return err
+		}
+		if n != len(added) {
+			return ErrSongNotFound
+		}
+	}
+
+	order = KeepHidden(entries, func(songID int) bool { return live[songID] }, order)
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM playlist_song WHERE id_playlist = $1;`, id); err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	songIDs := make([]int64, len(order))
+	for i, songID := range order {
+		songIDs[i] = int64(songID)
+	}
+
+	query = `INSERT INTO playlist_song (id_playlist, id_song, position)
+				SELECT $1, t.id_song, t.position FROM unnest($2::int[]) WITH ORDINALITY AS t(id_song, position);`
+
+	if _, err = tx.ExecContext(ctx, query, id, pq.Array(songIDs)); err != nil {
+		if pqCode(err) == codeForeignKeyViolation {
+			return ErrSongNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMoveSong(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    []int
+		songID   int
+		position int
+		want     []int
+	}{
+		{"into an empty playlist", nil, 7, 1, []int{7}},
+		{"insert at the front", []int{1, 2, 3}, 7, 1, []int{7, 1, 2, 3}},
+		{"insert in the middle", []int{1, 2, 3}, 7, 2, []int{1, 7, 2, 3}},
+		{"insert right after the end", []int{1, 2, 3}, 7, 4, []int{1, 2, 3, 7}},
+		{"past the end appends", []int{1, 2, 3}, 7, 99, []int{1, 2, 3, 7}},
+		{"zero position appends", []int{1, 2, 3}, 7, 0, []int{1, 2, 3, 7}},
+		{"negative position appends", []int{1, 2, 3}, 7, -1, []int{1, 2, 3, 7}},
+		{"move down", []int{1, 2, 3, 4}, 1, 3, []int{2, 3, 1, 4}},
+		{"move up", []int{1, 2, 3, 4}, 4, 1, []int{4, 1, 2, 3}},
+		{"move to the same place", []int{1, 2, 3}, 2, 2, []int{1, 2, 3}},
+		{"move last past the end", []int{1, 2, 3}, 3, 10, []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := slices.Clone(tt.order)
+
+			got := MoveSong(tt.order, tt.songID, tt.position)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("MoveSong(%v, %d, %d) = %v, want %v", tt.order, tt.songID, tt.position, got, tt.want)
+			}
+			if !slices.Equal(tt.order, before) {
+				t.Errorf("MoveSong changed its input to %v", tt.order)
+			}
+		})
+	}
+}
+
+func TestKeepHidden(t *testing.T) {
+	// Songs 10 and up are in the trash.
+	isVisible := func(songID int) bool { return songID < 10 }
+
+	tests := []struct {
+		name    string
+		entries []int
+		visible []int
+		want    []int
+	}{
+		{"nothing hidden", []int{1, 2, 3}, []int{3, 1, 2}, []int{3, 1, 2}},
+		{"hidden at the front stays there", []int{10, 1, 2}, []int{2, 1}, []int{10, 2, 1}},
+		{"hidden follows its song", []int{1, 10, 2, 3}, []int{2, 3, 1}, []int{2, 3, 1, 10}},
+		{"song added before a hidden one", []int{1, 10, 2}, MoveSong([]int{1, 2}, 7, 2), []int{1, 10, 7, 2}},
+		{"hidden after a removed song follows the one before", []int{1, 2, 10, 11, 3}, []int{1, 3}, []int{1, 10, 11, 3}},
+		{"hidden after a removed first song goes to the front", []int{1, 10, 2}, []int{2}, []int{10, 2}},
+		{"only hidden songs", []int{10, 11}, nil, []int{10, 11}},
+		{"trashed song added again is not doubled", []int{1, 10}, []int{1, 10}, []int{1, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeepHidden(tt.entries, isVisible, tt.visible); !slices.Equal(got, tt.want) {
+				t.Errorf("KeepHidden(%v, %v) = %v, want %v", tt.entries, tt.visible, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Play is one listen of a user library song.
+type Play struct {
+	SongID   int       `json:"songId"`
+	PlayedAt time.Time `json:"playedAt"`
+	// Duration is how many seconds of the song were listened to, nil when unknown.
+	Duration *int `json:"duration"`
+}
+
+type RecentPlay struct {
+	Songs    Songs     `json:"songs"`
+	PlayedAt time.Time `json:"playedAt"`
+	Duration *int      `json:"duration"`
+}
+
+// PlayedSong is a song with the number of its plays in a window and the seconds listened
+// in those of them that reported a duration.
+type PlayedSong struct {
+	Songs    Songs `json:"songs"`
+	Plays    int   `json:"plays"`
+	Listened int   `json:"listened"`
+}
+
+type PlayedArtist struct {
+	ArtistID int    `json:"artistId"`
+	Artist   string `json:"artist"`
+	Plays    int    `json:"plays"`
+}
+
+// DayPlays is the number of plays on a day, in UTC.
+type DayPlays struct {
+	Day   string `json:"day"`
+	Plays int    `json:"plays"`
+}
+
+const (
+	// DefaultPlayWindow is how far back statistics look when no start is given.
+	DefaultPlayWindow = 30 * 24 * time.Hour
+	// MaxPlayDays bounds the days of a daily histogram.
+	MaxPlayDays = 366
+)
+
+// PlayQuery is a window of plays, From inclusive and To exclusive, and how many songs or
+// artists to return.
+type PlayQuery struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+func (q *PlayQuery) Normalize() error {
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultPlayWindow)
+	}
+	if !q.From.Before(q.To) {
+		return invalidQuery("from", "from must be before to")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+
+	return nil
+}
+
+// Days returns the UTC days the window touches, in order.
+func (q PlayQuery) Days() []string {
+	var days []string
+	for d := q.From.UTC().Truncate(24 * time.Hour); d.Before(q.To); d = d.AddDate(0, 0, 1) {
+		days = append(days, d.Format(time.DateOnly))
+	}
+	return days
+}
+
+// FillDays turns plays counted per day into a histogram of every day of the window,
+// days without plays included.
+func FillDays(q PlayQuery, counts map[string]int) []DayPlays {
+	days := q.Days()
+
+	histogram := make([]DayPlays, 0, len(days))
+	for _, day := range days {
+		histogram = append(histogram, DayPlays{Day: day, Plays: counts[day]})
+	}
+
+	return histogram
+}
+
+// AddPlays writes a batch of plays in one statement. Plays of songs that are not in the
+// library, or were deleted since, are dropped; the number written is returned.
+func (s *Storage) AddPlays(ctx context.Context, plays []Play, log *slog.Logger) (int, error) {
+	const op = "storage.postgres.AddPlays()"
+
+	ctx, span := startSpan(ctx, "AddPlays")
+	defer span.End()
+
+	songIDs := make([]int64, len(plays))
+	playedAt := make([]string, len(plays))
+	durations := make([]sql.NullInt64, len(plays))
+	for i, p := range plays {
+		songIDs[i] = int64(p.SongID)
+		playedAt[i] = p.PlayedAt.Format(time.RFC3339Nano)
+		if p.Duration != nil {
+			durations[i] = sql.NullInt64{Int64: int64(*p.Duration), Valid: true}
+		}
+	}
+
+	query := `INSERT INTO play (id_song, played_at, duration)
+				SELECT t.id_song, t.played_at, t.duration
+				FROM unnest($1::int[], $2::timestamptz[], $3::int[]) AS t(id_song, played_at, duration)
+				WHERE EXISTS (SELECT 1 FROM song s WHERE s.id = t.id_song AND s.deleted_at IS NULL);`
+
+	res, err := s.db.ExecContext(ctx, query, pq.Array(songIDs), pq.Array(playedAt), pq.Array(durations))
+	if err != nil {
+		log.Error("Error to insert", "error", err, "operation", op)
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (s *Storage) RecentPlays(ctx context.Context, limit int, log *slog.Logger) ([]RecentPlay, error) {
+	const op = "storage.postgres.RecentPlays()"
+
+	ctx, span := startSpan(ctx, "RecentPlays")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), p.played_at, p.duration
+				FROM play p
+				JOIN song s ON s.id = p.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				ORDER BY p.played_at DESC, p.id DESC
+				LIMIT $1;`
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		log.Error("Error to get plays", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	plays := []RecentPlay{}
+
+	for rows.Next() {
+		var p RecentPlay
+		err = rows.Scan(&p.Songs.ID,
+			&p.Songs.Song.Group,
+			&p.Songs.Song.Name,
+			&p.Songs.InfoSong.Text,
+			&p.Songs.InfoSong.ReleaseDate,
+			&p.Songs.InfoSong.Link,
+			&p.PlayedAt,
+			&p.Duration)
+		if err != nil {
+			log.Error("Error to get plays", "error", err, "operation", op)
+			return nil, err
+		}
+		plays = append(plays, p)
+	}
+
+	return plays, rows.Err()
+}
+
+// MostPlayedSongs returns the songs played most often in the window; songs played as
+// often are ordered by ID.
+func (s *Storage) MostPlayedSongs(ctx context.Context, q PlayQuery, log *slog.Logger) ([]PlayedSong, error) {
+	const op = "storage.postgres.MostPlayedSongs()"
+
+	ctx, span := startSpan(ctx, "MostPlayedSongs")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), p.plays, p.listened
+				FROM (SELECT id_song, count(*) AS plays, COALESCE(sum(duration), 0) AS listened
+						FROM play
+						WHERE played_at >= $1 AND played_at < $2
+						GROUP BY id_song) p
+				JOIN song s ON s.id = p.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				ORDER BY p.plays DESC, s.id
+				LIMIT $3;`
+
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To, q.Limit)
+	if err != nil {
+		log.Error("Error to get play counts", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []PlayedSong{}
+
+	for rows.Next() {
+		var ps PlayedSong
+		err = rows.Scan(&ps.Songs.ID,
+			&ps.Songs.Song.Group,
+			&ps.Songs.Song.Name,
+			&ps.Songs.InfoSong.Text,
+			&ps.Songs.InfoSong.ReleaseDate,
+			&ps.Songs.InfoSong.Link,
+			&ps.Plays,
+			&ps.Listened)
+		if err != nil {
+			log.Error("Error to get play counts", "error", err, "operation", op)
+			return nil, err
+		}
+		songs = append(songs, ps)
+	}
+
+	return songs, rows.Err()
+}
+
+// MostPlayedArtists returns the artists whose songs were played most often in the window.
+func (s *Storage) MostPlayedArtists(ctx context.Context, q PlayQuery, log *slog.Logger) ([]PlayedArtist, error) {
+	const op = "storage.postgres.MostPlayedArtists()"
+
+	ctx, span := startSpan(ctx, "MostPlayedArtists")
+	defer span.End()
+
+	query := `SELECT a.id, a.name, count(*)
+				FROM play p
+				JOIN song s ON s.id = p.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				WHERE p.played_at >= $1 AND p.played_at < $2
+				GROUP BY a.id
+				ORDER BY count(*) DESC, a.id
+				LIMIT $3;`
+
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To, q.Limit)
+	if err != nil {
+		log.Error("Error to get play counts", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	artists := []PlayedArtist{}
+
+	for rows.Next() {
+		var pa PlayedArtist
+		if err = rows.Scan(&pa.ArtistID, &pa.Artist, &pa.Plays); err != nil {
+			log.Error("Error to get play counts", "error", err, "operation", op)
+			return nil, err
+		}
+		artists = append(artists, pa)
+	}
+
+	return artists, rows.Err()
+}
+
+// DailyPlays counts the plays of each UTC day of the window.
+func (s *Storage) DailyPlays(ctx context.Context, q PlayQuery, log *slog.Logger) ([]DayPlays, error) {
+	const op = "storage.postgres.DailyPlays()"
+
+	ctx, span := startSpan(ctx, "DailyPlays")
+	defer span.End()
+
+	query := `SELECT to_char(p.played_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'), count(*)
+				FROM play p
+				JOIN song s ON s.id = p.id_song AND s.deleted_at IS NULL
+				WHERE p.played_at >= $1 AND p.played_at < $2
+				GROUP BY 1;`
+
+	rows, err := s.db.QueryContext(ctx, query, q.From, q.To)
+	if err != nil {
+		log.Error("Error to get play counts", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var (
+			day   string
+			plays int
+		)
+		if err = rows.Scan(&day, &plays); err != nil {
+			log.Error("Error to get play counts", "error", err, "operation", op)
+			return nil, err
+		}
+		counts[day] = plays
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return FillDays(q, counts), nil
+}
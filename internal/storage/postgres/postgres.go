@@ -1,20 +1,29 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/lib/pq"
-	"io/ioutil"
+	"errors"
+	"github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"log/slog"
-	"net/http"
-	"os"
+	"songLibrary/internal/tracing"
 	"time"
 )
 
 type Library struct {
-	Songs Songs `json:"songs"`
+	Songs  Songs    `json:"songs"`
+	Tags   []string `json:"tags"`
+	Genres []string `json:"genres"`
+
+	// AverageRating is nil for a song nobody rated.
+	AverageRating *float64 `json:"averageRating"`
+	RatingCount   int      `json:"ratingCount"`
 }
 
 type Songs struct {
+	ID       int      `json:"id"`
 	Song     Song     `json:"song"`
 	InfoSong InfoSong `json:"info_song"`
 }
@@ -30,6 +39,19 @@ type InfoSong struct {
 	Link        string     `json:"link"`
 }
 
+// SongCounts is how many songs the user library, the trash and the global catalog hold.
+type SongCounts struct {
+	Library int `json:"library"`
+	Trash   int `json:"trash"`
+	Catalog int `json:"catalog"`
+}
+
+// ErrSongExists means the user library already has the song by that artist.
+var ErrSongExists = errors.New("song is already in the library")
+
+// ErrSongInTrash means the song by that artist was deleted and can be restored from the trash.
+var ErrSongInTrash = errors.New("song is in the trash")
+
 type Storage struct {
 	db *sql.DB
 }
@@ -38,76 +60,290 @@ func NewStorage(db *sql.DB) *Storage {
 	return &Storage{db: db}
 }
 
-func (s *Storage) AddSong(song Song, log *slog.Logger) (int, error) {
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the connection pool of the storage, for watching its statistics.
+func (s *Storage) DB() *sql.DB {
+	return s.db
+}
+
+// Ping checks the database can be reached, opening a connection if the pool has none.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckLibrary checks the global Library catalog can be read.
+func (s *Storage) CheckLibrary(ctx context.Context) error {
+	var one int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM Library LIMIT 1;`).Scan(&one)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// startSpan starts the span of the storage operation name; its statements run in the
+// returned context.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "postgres."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(name)))
+}
+
+// AddSong inserts a song together with its info in one transaction, so a failure leaves
+// nothing behind.
+func (s *Storage) AddSong(ctx context.Context, song Song, info InfoSong, log *slog.Logger) (Songs, error) {
 	const op = "storage.postgres.AddSong()"
 
-	query := `INSERT INTO song (song, music_group) VALUES ($1, $2) returning id`
+	ctx, span := startSpan(ctx, "AddSong")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return Songs{}, err
+	}
+	defer tx.Rollback()
+
+	// The group is stored under the artist it names or is an alias of, created if needed.
+	var artistID int
+
+	err = tx.QueryRowContext(ctx, `SELECT resolve_artist($1)`, song.Group).Scan(&artistID)
+	if err != nil {
+		log.Error("Error to resolve artist", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	query := `INSERT INTO song (song, id_artist) VALUES ($1, $2)
+				returning id, (SELECT name FROM artist WHERE id = $2)`
 
 	var id int
 
-	err := s.db.QueryRow(query, song.Name, song.Group).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, song.Name, artistID).Scan(&id, &song.Group)
+	if err != nil {
+		if pqCode(err) == codeUniqueViolation {
+			// The failed transaction is of no more use and holds a connection.
+			tx.Rollback()
+			return Songs{}, s.existingSong(ctx, artistID, song.Name, op, log)
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	query = `INSERT INTO infosong (id_song, releasedate, text, link) VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.ExecContext(ctx, query, id, info.ReleaseDate, info.Text, info.Link)
+	if err != nil {
+		log.Error("Error to insert", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	return Songs{ID: id, Song: song, InfoSong: info}, nil
+}
+
+// existingSong tells whether a song AddSong could not insert is in the library or the trash.
+// A song purged since counts as existing, as it did when the insert failed.
+func (s *Storage) existingSong(ctx context.Context, artistID int, song, op string, log *slog.Logger) error {
+	var trashed bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT deleted_at IS NOT NULL FROM song WHERE id_artist = $1 AND song = $2;`, artistID, song).Scan(&trashed)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error("Error to get song", "error", err, "operation", op)
+		return err
+	}
+	if trashed {
+		return ErrSongInTrash
+	}
+
+	return ErrSongExists
+}
+
+// liveSong returns ErrSongNotFound unless the song is in the user library. Inserts that only
+// take songs of the library use it to tell a missing song from a row that was already there.
+func liveSong(ctx context.Context, db querier, id int) error {
+	var exists bool
+
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM song WHERE id = $1 AND deleted_at IS NULL);`, id).Scan(&exists)
 	if err != nil {
-		log.Error("Error to insert", op)
-		return http.StatusBadRequest, err
+		return err
+	}
+	if !exists {
+		return ErrSongNotFound
 	}
 
-	query = `INSERT INTO infosong (id_song) VALUES ($1)`
+	return nil
+}
+
+// FindSong returns the user library song with the name by the artist the group names or
+// is an alias of.
+func (s *Storage) FindSong(ctx context.Context, group, song string, log *slog.Logger) (Songs, error) {
+	const op = "storage.postgres.FindSong()"
+
+	ctx, span := startSpan(ctx, "FindSong")
+	defer span.End()
 
-	_, err = s.db.Exec(query, id)
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, '')
+				FROM song s
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE s.id_artist = find_artist($1) AND s.song = $2 AND s.deleted_at IS NULL;`
+
+	var found Songs
+
+	err := s.db.QueryRowContext(ctx, query, group, song).Scan(&found.ID,
+		&found.Song.Group,
+		&found.Song.Name,
+		&found.InfoSong.Text,
+		&found.InfoSong.ReleaseDate,
+		&found.InfoSong.Link)
 	if err != nil {
-		log.Error("Error to insert", op)
-		return http.StatusBadRequest, err
+		if err == sql.ErrNoRows {
+			return Songs{}, ErrSongNotFound
+		}
+		log.Error("Error to get song", "error", err, "operation", op)
+		return Songs{}, err
 	}
 
-	return id, nil
+	return found, nil
 }
 
-func (s *Storage) ChangeInfo(id int, info InfoSong, log *slog.Logger) (int, error) {
+// ChangeInfo sets the text and link of a song, and its release date when info has one, and
+// records the change as a revision made by editor. A version other than 0 must match the
+// one of the song. It returns the version the song is at after the change.
+func (s *Storage) ChangeInfo(ctx context.Context, id int, info InfoSong, editor string, ifVersion int, log *slog.Logger) (int, error) {
 	const op = "storage.postgres.AddInfo()"
 
-	query := `
-		UPDATE InfoSong
-		SET 
-		    releaseDate = COALESCE($1, releaseDate),
-		    text = COALESCE($2, text),
-		    link = COALESCE($3, link)
-		WHERE id_song = $4;
-	`
+	ctx, span := startSpan(ctx, "ChangeInfo")
+	defer span.End()
 
-	_, err := s.db.Exec(query, info.ReleaseDate, info.Text, info.Link, id)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Error("Error to update", op)
-		return http.StatusBadRequest, err
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	return http.StatusOK, nil
+	before, version, err := lockInfo(ctx, tx, id, ifVersion)
+	if err != nil {
+		if !errors.Is(err, ErrSongNotFound) && !errors.Is(err, ErrVersionMismatch) {
+			log.Error("Error to get song info", "error", err, "operation", op)
+		}
+		return 0, err
+	}
+
+	after := before
+	if info.ReleaseDate != nil {
+		after.ReleaseDate = info.ReleaseDate
+	}
+	after.Text = info.Text
+	after.Link = info.Link
+
+	rev, err := writeInfo(ctx, tx, id, version, before, after, editor, nil)
+	if err != nil {
+		log.Error("Error to update", "error", err, "operation", op)
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return 0, err
+	}
+
+	if rev.Revision == 0 {
+		return version, nil
+	}
+	return rev.Version, nil
 }
 
-func (s *Storage) DeleteSong(id int, log *slog.Logger) (sql.Result, error) {
+// DeleteSong moves a song to the trash. It keeps its info, playlists, albums, labels,
+// ratings and plays until it is restored or purged. A version other than 0 must match the
+// one of the song.
+func (s *Storage) DeleteSong(ctx context.Context, id, ifVersion int, log *slog.Logger) (sql.Result, error) {
 	const op = "storage.postgres.DeleteInfo()"
 
-	query := `DELETE FROM Song WHERE id = $1;`
+	ctx, span := startSpan(ctx, "DeleteSong")
+	defer span.End()
 
-	res, err := s.db.Exec(query, id)
+	query := `UPDATE song SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2);`
+
+	res, err := s.db.ExecContext(ctx, query, id, ifVersion)
 	if err != nil {
-		log.Error("Error to delete", op)
+		log.Error("Error to delete", "operation", op)
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 && ifVersion != 0 {
+		var exists bool
+		err = s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM song WHERE id = $1 AND deleted_at IS NULL);`, id).Scan(&exists)
+		if err != nil {
+			log.Error("Error to get song", "error", err, "operation", op)
+			return nil, err
+		}
+		if exists {
+			return nil, ErrVersionMismatch
+		}
 	}
 
 	return res, nil
 }
 
-func (s *Storage) GetText(id int, log *slog.Logger) (string, error) {
+// GetSong returns a library song with its version.
+func (s *Storage) GetSong(ctx context.Context, id int, log *slog.Logger) (Songs, int, error) {
+	const op = "storage.postgres.GetSong()"
+
+	ctx, span := startSpan(ctx, "GetSong")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), s.version
+				FROM song s
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE s.id = $1 AND s.deleted_at IS NULL;`
+
+	var (
+		song    Songs
+		version int
+	)
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&song.ID,
+		&song.Song.Group,
+		&song.Song.Name,
+		&song.InfoSong.Text,
+		&song.InfoSong.ReleaseDate,
+		&song.InfoSong.Link,
+		&version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Songs{}, 0, ErrSongNotFound
+		}
+		log.Error("Error to get song", "error", err, "operation", op)
+		return Songs{}, 0, err
+	}
+
+	return song, version, nil
+}
+
+func (s *Storage) GetText(ctx context.Context, id int, log *slog.Logger) (string, error) {
 	const op = "storage.postgres.GetText()"
 
-	query := `SELECT text FROM infosong WHERE id_song = $1;`
+	ctx, span := startSpan(ctx, "GetText")
+	defer span.End()
+
+	query := `SELECT COALESCE(i.text, '') FROM infosong i JOIN song s ON s.id = i.id_song WHERE i.id_song = $1 AND s.deleted_at IS NULL;`
 
 	var text string
 
-	err := s.db.QueryRow(query, id).Scan(&text)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&text)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			log.Warn("No song text found", "id_song", id, "operation", op)
-			return "", nil // Можно вернуть пустую строку или специальное сообщение
+			return "", ErrSongNotFound
 		}
 		log.Error("Error getting song text", "error", err, "operation", op)
 		return "", err
@@ -116,166 +352,186 @@ func (s *Storage) GetText(id int, log *slog.Logger) (string, error) {
 	return text, nil
 }
 
-func (s *Storage) GetLibrary(log *slog.Logger) ([]Library, error) {
+func (s *Storage) GetLibrary(ctx context.Context, q LibraryQuery, log *slog.Logger) (LibraryPage, error) {
 
 	const op = "storage.postgres.GetLibrary()"
 
-	query := `SELECT s.id, s.music_group, s.song, i.text, i.releasedate, i.link
-				FROM song s
-				JOIN infosong i ON s.id = i.id_song;
-				`
+	ctx, span := startSpan(ctx, "GetLibrary")
+	defer span.End()
 
-	var library []Library
+	cols := userLibraryColumns
+	from := ` FROM song s JOIN artist a ON a.id = s.id_artist JOIN infosong i ON s.id = i.id_song`
 
-	rows, err := s.db.Query(query)
-	if err != nil {
-		log.Error("Error to get songs", op)
+	if q.AlbumDates {
+		cols.releaseDate = "COALESCE(i.releasedate, ad.releasedate)"
+		from += albumReleaseDate
 	}
 
-	for rows.Next() {
-		var lib Library
-		var id int64
-		err = rows.Scan(&id,
-			&lib.Songs.Song.Group,
-			&lib.Songs.Song.Name,
-			&lib.Songs.InfoSong.Text,
-			&lib.Songs.InfoSong.ReleaseDate,
-			&lib.Songs.InfoSong.Link)
-		if err != nil {
-			log.Error("Error to get songs", op)
-			return nil, err
-		}
-
-		library = append(library, lib)
-	}
-
-	return library, nil
+	return s.libraryPage(ctx, q, cols, from,
+		`SELECT s.id, a.name, s.song, COALESCE(i.text, ''), `+cols.releaseDate+`, COALESCE(i.link, '')`, op, log)
 }
 
-func (s *Storage) GetInfo(song, group string, log *slog.Logger) (InfoSong, error) {
+func (s *Storage) GetInfo(ctx context.Context, group, song string, log *slog.Logger) (InfoSong, error) {
 
 	const op = "storage.postgres.GetInfo()"
 
-	query := `SELECT text, releasedate, link FROM Library WHERE music_group = $1 AND song = $2;`
+	ctx, span := startSpan(ctx, "GetInfo")
+	defer span.End()
+
+	query := `SELECT COALESCE(text, ''), releasedate, COALESCE(link, '') FROM Library WHERE id_artist = find_artist($1) AND song = $2;`
 
 	var infoSong InfoSong
 
-	rows, err := s.db.Query(query, song, group)
+	err := s.db.QueryRowContext(ctx, query, group, song).Scan(&infoSong.Text, &infoSong.ReleaseDate, &infoSong.Link)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return InfoSong{}, ErrSongNotFound
+		}
+		log.Error("Error to get info", "error", err, "operation", op)
+		return InfoSong{}, err
+	}
+
+	return infoSong, nil
+}
+
+// ExportLibrary calls fn with each user library song in ID order as it is read, so the
+// library is never held in memory at once. Lyrics are read only when asked for. An error
+// from fn stops the export and is returned.
+func (s *Storage) ExportLibrary(ctx context.Context, lyrics bool, fn func(song Songs) error, log *slog.Logger) error {
+	const op = "storage.postgres.ExportLibrary()"
 
+	ctx, span := startSpan(ctx, "ExportLibrary")
+	defer span.End()
+
+	text := "''"
+	if lyrics {
+		text = "COALESCE(i.text, '')"
+	}
+
+	query := `SELECT s.id, a.name, s.song, ` + text + `, i.releasedate, COALESCE(i.link, '')
+				FROM song s
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE s.deleted_at IS NULL
+				ORDER BY s.id;`
+
+	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
-		log.Error("Error to get songs", op)
+		log.Error("Error to get songs", "error", err, "operation", op)
+		return err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		err = rows.Scan(&infoSong.Text,
-			&infoSong.ReleaseDate,
-			&infoSong.Link)
+		var song Songs
+		err = rows.Scan(&song.ID,
+			&song.Song.Group,
+			&song.Song.Name,
+			&song.InfoSong.Text,
+			&song.InfoSong.ReleaseDate,
+			&song.InfoSong.Link)
 		if err != nil {
-			log.Error("Error to get songs", op)
-			return InfoSong{}, err
+			log.Error("Error to get songs", "error", err, "operation", op)
+			return err
+		}
+
+		if err = fn(song); err != nil {
+			return err
 		}
 	}
 
-	return infoSong, nil
+	if err = rows.Err(); err != nil {
+		log.Error("Error to get songs", "error", err, "operation", op)
+		return err
+	}
+
+	return nil
 }
 
-func (s *Storage) GetLibraryMain(log *slog.Logger) ([]Library, error) {
+func (s *Storage) GetLibraryMain(ctx context.Context, q LibraryQuery, log *slog.Logger) (LibraryPage, error) {
 
 	const op = "storage.postgres.GetLibraryMain()"
 
-	query := `SELECT music_group, song, text, releasedate, link FROM library;`
+	ctx, span := startSpan(ctx, "GetLibraryMain")
+	defer span.End()
 
-	var library []Library
+	// Tags, genres and ratings belong to user library songs; a catalog song shows those of
+	// the same song in the user library.
+	from := ` FROM library l JOIN artist a ON a.id = l.id_artist
+				LEFT JOIN song us ON us.id_artist = l.id_artist AND us.song = l.song AND us.deleted_at IS NULL`
+
+	return s.libraryPage(ctx, q, mainLibraryColumns, from,
+		`SELECT l.id, a.name, l.song, l.text, l.releasedate, l.link`, op, log)
+}
+
+// libraryPage counts the songs matching q and reads the requested page of them.
+func (s *Storage) libraryPage(ctx context.Context, q LibraryQuery, cols libraryColumns, from, selectList, op string, log *slog.Logger) (LibraryPage, error) {
 
-	rows, err := s.db.Query(query)
+	conds, args := cols.filter(q)
+
+	var total int
+	err := s.db.QueryRowContext(ctx, `SELECT count(*)`+from+where(conds), args...).Scan(&total)
 	if err != nil {
-		log.Error("Error to get songs", op)
+		log.Error("Error to count songs", "error", err, "operation", op)
+		return LibraryPage{}, err
 	}
 
+	tail, args := cols.page(q, conds, args)
+
+	rows, err := s.db.QueryContext(ctx, selectList+cols.labels()+cols.ratings()+from+tail, args...)
+	if err != nil {
+		log.Error("Error to get songs", "error", err, "operation", op)
+		return LibraryPage{}, err
+	}
+	defer rows.Close()
+
+	var library []Library
+
 	for rows.Next() {
 		var lib Library
 		err = rows.Scan(
+			&lib.Songs.ID,
 			&lib.Songs.Song.Group,
 			&lib.Songs.Song.Name,
 			&lib.Songs.InfoSong.Text,
 			&lib.Songs.InfoSong.ReleaseDate,
-			&lib.Songs.InfoSong.Link)
+			&lib.Songs.InfoSong.Link,
+			pq.Array(&lib.Tags),
+			pq.Array(&lib.Genres),
+			&lib.AverageRating,
+			&lib.RatingCount)
 		if err != nil {
-			log.Error("Error to get songs", op)
-			return nil, err
+			log.Error("Error to get songs", "error", err, "operation", op)
+			return LibraryPage{}, err
 		}
 
 		library = append(library, lib)
 	}
-
-	return library, nil
-}
-
-func (s *Storage) CreateTable(log *slog.Logger) {
-	const op = "storage.postgres.CreateTable()"
-
-	createLibraryTable := `
-    CREATE TABLE IF NOT EXISTS Library(
-	id serial PRIMARY KEY,
-	music_group varchar(53) NOT NULL ,
-	song varchar(50) NOT NULL ,
-	text text NOT NULL ,
-	releasedate date NOT NULL ,
-	link varchar(70) NOT NULL ,
-	UNIQUE(music_group, song)
-	);`
-
-	createSongTable := `
-    CREATE TABLE IF NOT EXISTS song(
-	id serial PRIMARY KEY,
-	music_group varchar(53) NOT NULL ,
-	song varchar(50) NOT NULL ,
-	UNIQUE(music_group, song)
-	);`
-
-	createInfoSongTable := `
-    CREATE TABLE IF NOT EXISTS infosong(
-	id serial PRIMARY KEY,
-	id_song int references song(id) ON DELETE CASCADE,
-	releasedate date ,
-	text text ,
-	link varchar(70)
-	);`
-
-	_, err := s.db.Exec(createLibraryTable)
-	if err != nil {
-		log.Error("Error to create library table", op)
+	if err = rows.Err(); err != nil {
+		log.Error("Error to get songs", "error", err, "operation", op)
+		return LibraryPage{}, err
 	}
 
-	_, err = s.db.Exec(createSongTable)
-	if err != nil {
-		log.Error("Error to create song table", op)
-	}
+	return FinishPage(q, library, total), nil
+}
 
-	_, err = s.db.Exec(createInfoSongTable)
-	if err != nil {
-		log.Error("Error to create infosong table", op)
-	}
+func (s *Storage) CountSongs(ctx context.Context, log *slog.Logger) (SongCounts, error) {
+	const op = "storage.postgres.CountSongs()"
 
-	return
-}
+	ctx, span := startSpan(ctx, "CountSongs")
+	defer span.End()
 
-func (s *Storage) MigrateLibrary(log *slog.Logger) {
-	const op = "storage.postgres.executeSQLFile()"
+	query := `SELECT count(*) FILTER (WHERE deleted_at IS NULL), count(*) FILTER (WHERE deleted_at IS NOT NULL),
+				(SELECT count(*) FROM Library)
+				FROM song;`
 
-	initPath := os.Getenv("INIT_PATH")
-	if initPath == "" {
-		log.Error("No INIT_PATH environment variable found", op)
-	}
+	var counts SongCounts
 
-	sqlBytes, err := ioutil.ReadFile(initPath)
+	err := s.db.QueryRowContext(ctx, query).Scan(&counts.Library, &counts.Trash, &counts.Catalog)
 	if err != nil {
-		log.Error("Error to read init.sql", op)
+		log.Error("Error to count songs", "error", err, "operation", op)
+		return SongCounts{}, err
 	}
-	sqlStatement := string(sqlBytes)
 
-	_, err = s.db.Exec(sqlStatement)
-	if err != nil {
-		log.Error("Error to execute sql", op)
-	}
+	return counts, nil
 }
@@ -0,0 +1,317 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SortID          = "id"
+	SortGroup       = "group"
+	SortSong        = "song"
+	SortReleaseDate = "releaseDate"
+	SortLink        = "link"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// noReleaseDate stands in for a missing release date when sorting and paging, so songs
+// without one come first in ascending order.
+const noReleaseDate = "0001-01-01"
+
+// InvalidError is a query value Normalize rejects. Field names the request parameter it
+// came from.
+type InvalidError struct {
+	Field   string
+	Message string
+}
+
+func (e *InvalidError) Error() string {
+	return e.Message
+}
+
+func invalidQuery(field, message string) error {
+	return &InvalidError{Field: field, Message: message}
+}
+
+// LibraryQuery holds the filters, sort order and page requested for a library listing.
+type LibraryQuery struct {
+	ArtistID     int
+	Group        string
+	Song         string
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+	HasLink      *bool
+	SortBy       string
+	Desc         bool
+	Limit        int
+	Offset       int
+	Cursor       *Cursor
+
+	// Tag and Genre narrow the listing to songs with the tag or in the genre or one of its
+	// subgenres.
+	Tag   string
+	Genre string
+
+	// AlbumDates takes the release date of a user library song from its earliest album when
+	// the song has none of its own.
+	AlbumDates bool
+}
+
+// LibraryPage is one page of a library listing.
+type LibraryPage struct {
+	Items      []Library `json:"items"`
+	Total      int       `json:"total"`
+	Limit      int       `json:"limit"`
+	Offset     int       `json:"offset"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
+
+// Cursor points just past the last song of a page. It is handed to clients as an opaque
+// string and remembers the sort it was issued for.
+type Cursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Value  string `json:"v"`
+	ID     int    `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalidQuery("cursor", "malformed cursor")
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(raw, &c); err != nil || !ValidSort(c.SortBy) {
+		return nil, invalidQuery("cursor", "malformed cursor")
+	}
+
+	return &c, nil
+}
+
+func ValidSort(sortBy string) bool {
+	switch sortBy {
+	case SortID, SortGroup, SortSong, SortReleaseDate, SortLink:
+		return true
+	}
+	return false
+}
+
+// SortValue returns the value of the sort field of a song as it is stored in a cursor.
+func SortValue(sortBy string, s Songs) string {
+	switch sortBy {
+	case SortGroup:
+		return s.Song.Group
+	case SortSong:
+		return s.Song.Name
+	case SortReleaseDate:
+		if s.InfoSong.ReleaseDate == nil {
+			return noReleaseDate
+		}
+		return s.InfoSong.ReleaseDate.Format(time.DateOnly)
+	case SortLink:
+		return s.InfoSong.Link
+	}
+	return strconv.Itoa(s.ID)
+}
+
+// Normalize fills in defaults and checks that a cursor matches the requested sort.
+func (q *LibraryQuery) Normalize() error {
+	if q.SortBy == "" {
+		q.SortBy = SortID
+	}
+	if !ValidSort(q.SortBy) {
+		return invalidQuery("sort", fmt.Sprintf("unknown sort field %q", q.SortBy))
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.Offset < 0 {
+		return invalidQuery("offset", "offset must not be negative")
+	}
+
+	if q.Cursor != nil {
+		if q.Cursor.SortBy != q.SortBy || q.Cursor.Desc != q.Desc {
+			return invalidQuery("cursor", "cursor was issued for a different sort order")
+		}
+		q.Offset = 0
+	}
+
+	return nil
+}
+
+// libraryColumns names the SQL expressions a library listing filters and sorts on, so the
+// same query builder serves the user library and the global Library catalog.
+type libraryColumns struct {
+	id, artist, group, song, releaseDate, link string
+
+	// userSong is the id of the user library song tags, genres and ratings are looked up by.
+	userSong string
+
+	// deletedAt is the trash column of the listed songs, for listings that hide trashed songs.
+	deletedAt string
+}
+
+var (
+	userLibraryColumns = libraryColumns{
+		id:          "s.id",
+		artist:      "s.id_artist",
+		group:       "a.name",
+		song:        "s.song",
+		releaseDate: "i.releasedate",
+		link:        "i.link",
+		userSong:    "s.id",
+		deletedAt:   "s.deleted_at",
+	}
+	mainLibraryColumns = libraryColumns{
+		id:          "l.id",
+		artist:      "l.id_artist",
+		group:       "a.name",
+		song:        "l.song",
+		releaseDate: "l.releasedate",
+		link:        "l.link",
+		userSong:    "us.id",
+	}
+)
+
+// sortExpr returns the expression ORDER BY and the cursor comparison use for a sort field,
+// together with the type the cursor value is cast to.
+func (c libraryColumns) sortExpr(sortBy string) (string, string) {
+	switch sortBy {
+	case SortGroup:
+		return c.group, "text"
+	case SortSong:
+		return c.song, "text"
+	case SortReleaseDate:
+		return "COALESCE(" + c.releaseDate + ", DATE '" + noReleaseDate + "')", "date"
+	case SortLink:
+		return "COALESCE(" + c.link + ", '')", "text"
+	}
+	return c.id, "int"
+}
+
+// filter builds the WHERE conditions shared by the count and the page query.
+func (c libraryColumns) filter(q LibraryQuery) ([]string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if c.deletedAt != "" {
+		conds = append(conds, c.deletedAt+" IS NULL")
+	}
+	if q.ArtistID != 0 {
+		conds = append(conds, c.artist+" = "+arg(q.ArtistID))
+	}
+	if q.Group != "" {
+		conds = append(conds, c.artist+" = find_artist("+arg(q.Group)+")")
+	}
+	if q.Song != "" {
+		conds = append(conds, c.song+" ILIKE "+arg("%"+escapeLike(q.Song)+"%"))
+	}
+	if q.ReleasedFrom != nil {
+		conds = append(conds, c.releaseDate+" >= "+arg(q.ReleasedFrom.Format(time.DateOnly))+"::date")
+	}
+	if q.ReleasedTo != nil {
+		conds = append(conds, c.releaseDate+" <= "+arg(q.ReleasedTo.Format(time.DateOnly))+"::date")
+	}
+	if q.Tag != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM song_tag st WHERE st.id_song = "+c.userSong+" AND st.tag = "+arg(NormalizeTag(q.Tag))+")")
+	}
+	if q.Genre != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM song_genre sg WHERE sg.id_song = "+c.userSong+
+			" AND sg.id_genre IN (SELECT genre_subtree("+arg(q.Genre)+")))")
+	}
+	if q.HasLink != nil {
+		hasLink := "COALESCE(" + c.link + ", '') <> ''"
+		if !*q.HasLink {
+			hasLink = "NOT (" + hasLink + ")"
+		}
+		conds = append(conds, hasLink)
+	}
+
+	return conds, args
+}
+
+// labels selects the tags and the genres of each song, after its other columns.
+func (c libraryColumns) labels() string {
+	return `, COALESCE((SELECT array_agg(st.tag ORDER BY st.tag) FROM song_tag st WHERE st.id_song = ` + c.userSong + `), '{}'),
+			COALESCE((SELECT array_agg(g.name ORDER BY g.name) FROM song_genre sg JOIN genre g ON g.id = sg.id_genre
+				WHERE sg.id_song = ` + c.userSong + `), '{}')`
+}
+
+// ratings selects the average and the number of the ratings of each song, after its labels.
+func (c libraryColumns) ratings() string {
+	return `, (SELECT round(avg(r.stars), 2)::float8 FROM rating r WHERE r.id_song = ` + c.userSong + `),
+			(SELECT count(*) FROM rating r WHERE r.id_song = ` + c.userSong + `)`
+}
+
+// page appends the cursor condition, ORDER BY, LIMIT and OFFSET to a filtered query.
+// One extra row is requested so FinishPage can tell whether there is a next page.
+func (c libraryColumns) page(q LibraryQuery, conds []string, args []any) (string, []any) {
+	expr, typ := c.sortExpr(q.SortBy)
+
+	direction, cmp := "ASC", ">"
+	if q.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if q.Cursor != nil {
+		args = append(args, q.Cursor.Value, q.Cursor.ID)
+		conds = append(conds, fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d)", expr, c.id, cmp, len(args)-1, typ, len(args)))
+	}
+
+	args = append(args, q.Limit+1, q.Offset)
+	tail := fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT $%d OFFSET $%d", expr, direction, c.id, direction, len(args)-1, len(args))
+
+	return where(conds) + tail, args
+}
+
+func where(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// FinishPage trims the extra row fetched past the limit and fills in the next cursor.
+func FinishPage(q LibraryQuery, items []Library, total int) LibraryPage {
+	p := LibraryPage{Items: items, Total: total, Limit: q.Limit, Offset: q.Offset}
+
+	if len(items) > q.Limit {
+		p.Items = items[:q.Limit]
+		last := p.Items[len(p.Items)-1].Songs
+		p.NextCursor = Cursor{SortBy: q.SortBy, Desc: q.Desc, Value: SortValue(q.SortBy, last), ID: last.ID}.Encode()
+	}
+	if p.Items == nil {
+		p.Items = []Library{}
+	}
+
+	return p
+}
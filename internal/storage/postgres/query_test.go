@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{SortBy: SortID, Value: "42", ID: 42},
+		{SortBy: SortGroup, Desc: true, Value: "Muse", ID: 7},
+		{SortBy: SortReleaseDate, Value: noReleaseDate, ID: 1},
+		{SortBy: SortSong, Value: "Ünïcödé / \"quoted\"", ID: 3},
+	}
+
+	for _, want := range tests {
+		got, err := DecodeCursor(want.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%+v.Encode()) error: %v", want, err)
+		}
+		if *got != want {
+			t.Errorf("DecodeCursor(%+v.Encode()) = %+v", want, *got)
+		}
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	tests := map[string]string{
+		"not base64":     "%%%",
+		"not json":       base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"unknown sort":   base64.RawURLEncoding.EncodeToString([]byte(`{"s":"rating","v":"1","i":1}`)),
+		"missing sort":   base64.RawURLEncoding.EncodeToString([]byte(`{"v":"1","i":1}`)),
+		"empty document": "",
+	}
+
+	for name, s := range tests {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Errorf("%s: DecodeCursor(%q) succeeded", name, s)
+		}
+	}
+}
+
+func TestLibraryQueryNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       LibraryQuery
+		want    LibraryQuery
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			q:    LibraryQuery{},
+			want: LibraryQuery{SortBy: SortID, Limit: DefaultLimit},
+		},
+		{
+			name: "limit capped",
+			q:    LibraryQuery{SortBy: SortSong, Limit: MaxLimit + 1, Offset: 10},
+			want: LibraryQuery{SortBy: SortSong, Limit: MaxLimit, Offset: 10},
+		},
+		{
+			name: "cursor resets offset",
+			q:    LibraryQuery{SortBy: SortGroup, Desc: true, Offset: 5, Cursor: &Cursor{SortBy: SortGroup, Desc: true}},
+			want: LibraryQuery{SortBy: SortGroup, Desc: true, Limit: DefaultLimit, Cursor: &Cursor{SortBy: SortGroup, Desc: true}},
+		},
+		{
+			name:    "unknown sort",
+			q:       LibraryQuery{SortBy: "rating"},
+			wantErr: "sort",
+		},
+		{
+			name:    "negative offset",
+			q:       LibraryQuery{Offset: -1},
+			wantErr: "offset",
+		},
+		{
+			name:    "cursor of another sort",
+			q:       LibraryQuery{SortBy: SortSong, Cursor: &Cursor{SortBy: SortGroup}},
+			wantErr: "cursor",
+		},
+		{
+			name:    "cursor of another order",
+			q:       LibraryQuery{SortBy: SortSong, Cursor: &Cursor{SortBy: SortSong, Desc: true}},
+			wantErr: "cursor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.q
+			err := q.Normalize()
+			if tt.wantErr != "" {
+				var invalidErr *InvalidError
+				if !errors.As(err, &invalidErr) || invalidErr.Field != tt.wantErr {
+					t.Fatalf("Normalize() error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() error: %v", err)
+			}
+			if q.SortBy != tt.want.SortBy || q.Desc != tt.want.Desc || q.Limit != tt.want.Limit || q.Offset != tt.want.Offset {
+				t.Errorf("Normalize() = %+v, want %+v", q, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	from := time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC)
+	hasLink := false
+
+	tests := []struct {
+		name      string
+		q         LibraryQuery
+		wantConds []string
+		wantArgs  []any
+	}{
+		{
+			name:      "no filters keeps trashed songs out",
+			q:         LibraryQuery{},
+			wantConds: []string{"s.deleted_at IS NULL"},
+		},
+		{
+			name:      "song pattern is escaped",
+			q:         LibraryQuery{Song: `50%_off\`},
+			wantConds: []string{"s.deleted_at IS NULL", "s.song ILIKE $1"},
+			wantArgs:  []any{`%50\%\_off\\%`},
+		},
+		{
+			name:      "group and release date",
+			q:         LibraryQuery{Group: "Muse", ReleasedFrom: &from},
+			wantConds: []string{"s.deleted_at IS NULL", "s.id_artist = find_artist($1)", "i.releasedate >= $2::date"},
+			wantArgs:  []any{"Muse", "2001-02-03"},
+		},
+		{
+			name:      "without link",
+			q:         LibraryQuery{HasLink: &hasLink},
+			wantConds: []string{"s.deleted_at IS NULL", "NOT (COALESCE(i.link, '') <> '')"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, args := userLibraryColumns.filter(tt.q)
+			if strings.Join(conds, " AND ") != strings.Join(tt.wantConds, " AND ") {
+				t.Errorf("conds = %q, want %q", conds, tt.wantConds)
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("args[%d] = %v, want %v", i, args[i], tt.wantArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFinishPage(t *testing.T) {
+	items := func(ids ...int) []Library {
+		var lib []Library
+		for _, id := range ids {
+			lib = append(lib, Library{Songs: Songs{ID: id, Song: Song{Name: "song"}}})
+		}
+		return lib
+	}
+
+	q := LibraryQuery{SortBy: SortID, Limit: 2}
+
+	page := FinishPage(q, items(1, 2, 3), 3)
+	if len(page.Items) != 2 || page.Total != 3 {
+		t.Fatalf("FinishPage() = %d items of %d, want 2 of 3", len(page.Items), page.Total)
+	}
+	cursor, err := DecodeCursor(page.NextCursor)
+	if err != nil {
+		t.Fatalf("next cursor %q: %v", page.NextCursor, err)
+	}
+	if cursor.ID != 2 || cursor.Value != "2" {
+		t.Errorf("next cursor = %+v, want past song 2", *cursor)
+	}
+
+	page = FinishPage(q, items(1, 2), 2)
+	if page.NextCursor != "" {
+		t.Errorf("last page has next cursor %q", page.NextCursor)
+	}
+
+	page = FinishPage(q, nil, 0)
+	if page.Items == nil {
+		t.Error("empty page has nil items, want an empty list")
+	}
+}
@@ -0,0 +1,305 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	MinStars = 1
+	MaxStars = 5
+
+	// MaxUserIDLength is the longest user id favorites and ratings are stored under.
+	MaxUserIDLength = 100
+)
+
+type Favorite struct {
+	Songs   Songs     `json:"songs"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+type Rating struct {
+	Songs   Songs     `json:"songs"`
+	Stars   int       `json:"stars"`
+	RatedAt time.Time `json:"ratedAt"`
+}
+
+// RatedSong is a song with the average and the number of the ratings all users gave it.
+type RatedSong struct {
+	Songs         Songs   `json:"songs"`
+	AverageRating float64 `json:"averageRating"`
+	RatingCount   int     `json:"ratingCount"`
+}
+
+// TopRatedQuery narrows the top rated songs to an artist, by ID or by name or alias, and to
+// songs released in a year.
+type TopRatedQuery struct {
+	ArtistID int
+	Group    string
+	Year     int
+	Limit    int
+}
+
+func (q *TopRatedQuery) Normalize() {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+}
+
+var ErrNotRated = errors.New("song is not rated")
+
+func ValidStars(stars int) bool {
+	return stars >= MinStars && stars <= MaxStars
+}
+
+// AddFavorite marks a song as a favorite of the user; marking it again changes nothing.
+func (s *Storage) AddFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	const op = "storage.postgres.AddFavorite()"
+
+	ctx, span := startSpan(ctx, "AddFavorite")
+	defer span.End()
+
+	query := `INSERT INTO favorite (user_id, id_song)
+				SELECT $1, $2 WHERE EXISTS (SELECT 1 FROM song WHERE id = $2 AND deleted_at IS NULL)
+				ON CONFLICT DO NOTHING;`
+
+	res, err := s.db.ExecContext(ctx, query, userID, songID)
+	if err != nil {
+		if pqCode(err) == codeForeignKeyViolation {
+			return ErrSongNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if err = liveSong(ctx, s.db, songID); err != nil && !errors.Is(err, ErrSongNotFound) {
+			log.Error("Error to get song", "error", err, "operation", op)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RemoveFavorite unmarks a song; removing a song that is not a favorite is not an error.
+func (s *Storage) RemoveFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	const op = "storage.postgres.RemoveFavorite()"
+
+	ctx, span := startSpan(ctx, "RemoveFavorite")
+	defer span.End()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM favorite WHERE user_id = $1 AND id_song = $2;`, userID, songID); err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	return nil
+}
+
+// ListFavorites returns the favorites of the user, most recently added first.
+func (s *Storage) ListFavorites(ctx context.Context, userID string, log *slog.Logger) ([]Favorite, error) {
+	const op = "storage.postgres.ListFavorites()"
+
+	ctx, span := startSpan(ctx, "ListFavorites")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), f.created_at
+				FROM favorite f
+				JOIN song s ON s.id = f.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE f.user_id = $1
+				ORDER BY f.created_at DESC, s.id;`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Error("Error to get favorites", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	favorites := []Favorite{}
+
+	for rows.Next() {
+		var f Favorite
+		err = rows.Scan(&f.Songs.ID,
+			&f.Songs.Song.Group,
+			&f.Songs.Song.Name,
+			&f.Songs.InfoSong.Text,
+			&f.Songs.InfoSong.ReleaseDate,
+			&f.Songs.InfoSong.Link,
+			&f.AddedAt)
+		if err != nil {
+			log.Error("Error to get favorites", "error", err, "operation", op)
+			return nil, err
+		}
+		favorites = append(favorites, f)
+	}
+
+	return favorites, rows.Err()
+}
+
+// RateSong sets the stars the user gives a song, replacing an earlier rating.
+func (s *Storage) RateSong(ctx context.Context, userID string, songID, stars int, log *slog.Logger) error {
+	const op = "storage.postgres.RateSong()"
+
+	ctx, span := startSpan(ctx, "RateSong")
+	defer span.End()
+
+	query := `INSERT INTO rating (user_id, id_song, stars)
+				SELECT $1, $2, $3 WHERE EXISTS (SELECT 1 FROM song WHERE id = $2 AND deleted_at IS NULL)
+				ON CONFLICT (user_id, id_song) DO UPDATE SET stars = EXCLUDED.stars, rated_at = now();`
+
+	res, err := s.db.ExecContext(ctx, query, userID, songID, stars)
+	if err != nil {
+		if pqCode(err) == codeForeignKeyViolation {
+			return ErrSongNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return err
+	}
+
+	// A rating of a library song is always inserted or updated.
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSongNotFound
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveRating(ctx context.Context, userID string, songID int, log *slog.Logger) error {
+	const op = "storage.postgres.RemoveRating()"
+
+	ctx, span := startSpan(ctx, "RemoveRating")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rating WHERE user_id = $1 AND id_song = $2;`, userID, songID)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotRated
+	}
+
+	return nil
+}
+
+// ListRatings returns the ratings of the user, most recently rated first.
+func (s *Storage) ListRatings(ctx context.Context, userID string, log *slog.Logger) ([]Rating, error) {
+	const op = "storage.postgres.ListRatings()"
+
+	ctx, span := startSpan(ctx, "ListRatings")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), r.stars, r.rated_at
+				FROM rating r
+				JOIN song s ON s.id = r.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE r.user_id = $1
+				ORDER BY r.rated_at DESC, s.id;`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Error("Error to get ratings", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []Rating{}
+
+	for rows.Next() {
+		var r Rating
+		err = rows.Scan(&r.Songs.ID,
+			&r.Songs.Song.Group,
+			&r.Songs.Song.Name,
+			&r.Songs.InfoSong.Text,
+			&r.Songs.InfoSong.ReleaseDate,
+			&r.Songs.InfoSong.Link,
+			&r.Stars,
+			&r.RatedAt)
+		if err != nil {
+			log.Error("Error to get ratings", "error", err, "operation", op)
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+
+	return ratings, rows.Err()
+}
+
+// TopRated returns the rated songs with the best average rating first; of songs rated the
+// same, the one rated more often comes first.
+func (s *Storage) TopRated(ctx context.Context, q TopRatedQuery, log *slog.Logger) ([]RatedSong, error) {
+	const op = "storage.postgres.TopRated()"
+
+	ctx, span := startSpan(ctx, "TopRated")
+	defer span.End()
+
+	var (
+		conds []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.ArtistID != 0 {
+		conds = append(conds, "s.id_artist = "+arg(q.ArtistID))
+	}
+	if q.Group != "" {
+		conds = append(conds, "s.id_artist = find_artist("+arg(q.Group)+")")
+	}
+	if q.Year != 0 {
+		conds = append(conds, "EXTRACT(YEAR FROM i.releasedate) = "+arg(q.Year))
+	}
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''),
+					round(avg(r.stars), 2)::float8, count(*)
+				FROM rating r
+				JOIN song s ON s.id = r.id_song AND s.deleted_at IS NULL
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id` + where(conds) + `
+				GROUP BY s.id, a.name, i.text, i.releasedate, i.link
+				ORDER BY avg(r.stars) DESC, count(*) DESC, s.id
+				LIMIT ` + arg(q.Limit) + `;`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Error("Error to get top rated songs", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []RatedSong{}
+
+	for rows.Next() {
+		var rs RatedSong
+		err = rows.Scan(&rs.Songs.ID,
+			&rs.Songs.Song.Group,
+			&rs.Songs.Song.Name,
+			&rs.Songs.InfoSong.Text,
+			&rs.Songs.InfoSong.ReleaseDate,
+			&rs.Songs.InfoSong.Link,
+			&rs.AverageRating,
+			&rs.RatingCount)
+		if err != nil {
+			log.Error("Error to get top rated songs", "error", err, "operation", op)
+			return nil, err
+		}
+		songs = append(songs, rs)
+	}
+
+	return songs, rows.Err()
+}
@@ -0,0 +1,258 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"log/slog"
+	"time"
+)
+
+// Fields of InfoSong a revision records as changed.
+const (
+	FieldReleaseDate = "releaseDate"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+// Revision is the info of a song as a change left it. Revision 1 is the info before the
+// first change and has no changed fields. Version is the version of the song the change
+// left. Editor is empty when the change was made without a user, and RollbackOf names the
+// revision a rollback went back to.
+type Revision struct {
+	Revision      int       `json:"revision"`
+	SongID        int       `json:"songId"`
+	Version       int       `json:"version,omitempty"`
+	InfoSong      InfoSong  `json:"info_song"`
+	ChangedFields []string  `json:"changedFields"`
+	Editor        string    `json:"editor,omitempty"`
+	RollbackOf    *int      `json:"rollbackOf,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrRevisionCurrent  = errors.New("info already matches the revision")
+
+	// ErrVersionMismatch means the song changed since the version a change was made against.
+	ErrVersionMismatch = errors.New("song version does not match")
+)
+
+// ChangedFields returns the fields that differ between two versions of the info of a song.
+func ChangedFields(before, after InfoSong) []string {
+	fields := []string{}
+	if formatDate(before.ReleaseDate) != formatDate(after.ReleaseDate) {
+		fields = append(fields, FieldReleaseDate)
+	}
+	if before.Text != after.Text {
+		fields = append(fields, FieldText)
+	}
+	if before.Link != after.Link {
+		fields = append(fields, FieldLink)
+	}
+	return fields
+}
+
+func formatDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(time.DateOnly)
+}
+
+// lockInfo reads the info and the version of a library song and locks them until tx ends,
+// so changes to one song and their revision numbers are made one at a time. A version other
+// than 0 must match the one of the song, or ErrVersionMismatch is returned.
+func lockInfo(ctx context.Context, tx *sql.Tx, id, ifVersion int) (InfoSong, int, error) {
+	query := `SELECT i.releasedate, COALESCE(i.text, ''), COALESCE(i.link, ''), s.version
+				FROM infosong i
+				JOIN song s ON s.id = i.id_song
+				WHERE i.id_song = $1 AND s.deleted_at IS NULL
+				FOR UPDATE;`
+
+	var (
+		info    InfoSong
+		version int
+	)
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&info.ReleaseDate, &info.Text, &info.Link, &version)
+	if err == sql.ErrNoRows {
+		return InfoSong{}, 0, ErrSongNotFound
+	}
+	if err == nil && ifVersion != 0 && ifVersion != version {
+		return InfoSong{}, 0, ErrVersionMismatch
+	}
+
+	return info, version, err
+}
+
+// writeInfo sets the info of a song at version to after, bumps the version and records the
+// change as a revision. Nothing is recorded when after changes nothing; the zero revision
+// is returned then.
+func writeInfo(ctx context.Context, tx *sql.Tx, id, version int, before, after InfoSong, editor string, rollbackOf *int) (Revision, error) {
+	fields := ChangedFields(before, after)
+	if len(fields) == 0 {
+		return Revision{}, nil
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE infosong SET releasedate = $1, text = $2, link = $3 WHERE id_song = $4;`,
+		after.ReleaseDate, after.Text, after.Link, id)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE song SET version = version + 1 WHERE id = $1;`, id); err != nil {
+		return Revision{}, err
+	}
+
+	var last int
+	if err = tx.QueryRowContext(ctx, `SELECT COALESCE(max(revision), 0) FROM infosong_revision WHERE id_song = $1;`, id).Scan(&last); err != nil {
+		return Revision{}, err
+	}
+
+	query := `INSERT INTO infosong_revision (id_song, revision, releasedate, text, link, changed_fields, editor, rollback_of, version)
+				VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+				RETURNING created_at;`
+
+	// The info from before the first change is kept as revision 1.
+	if last == 0 {
+		last++
+		_, err = tx.ExecContext(ctx, query, id, last, before.ReleaseDate, before.Text, before.Link, pq.Array([]string{}), "", nil, version)
+		if err != nil {
+			return Revision{}, err
+		}
+	}
+
+	rev := Revision{Revision: last + 1, SongID: id, Version: version + 1, InfoSong: after, ChangedFields: fields, Editor: editor, RollbackOf: rollbackOf}
+
+	err = tx.QueryRowContext(ctx, query, id, rev.Revision, after.ReleaseDate, after.Text, after.Link, pq.Array(fields), editor, rollbackOf, rev.Version).Scan(&rev.CreatedAt)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
+
+const revisionSelect = `SELECT r.revision, r.id_song, COALESCE(r.version, 0), r.releasedate, COALESCE(r.text, ''), COALESCE(r.link, ''),
+			r.changed_fields, COALESCE(r.editor, ''), r.rollback_of, r.created_at
+			FROM infosong_revision r
+			JOIN song s ON s.id = r.id_song AND s.deleted_at IS NULL`
+
+func scanRevision(row interface{ Scan(...any) error }) (Revision, error) {
+	var r Revision
+	err := row.Scan(&r.Revision, &r.SongID, &r.Version, &r.InfoSong.ReleaseDate, &r.InfoSong.Text, &r.InfoSong.Link,
+		pq.Array(&r.ChangedFields), &r.Editor, &r.RollbackOf, &r.CreatedAt)
+	if r.ChangedFields == nil {
+		r.ChangedFields = []string{}
+	}
+	return r, err
+}
+
+// ListRevisions returns the revisions of a library song, newest first. A song whose info
+// never changed has none.
+func (s *Storage) ListRevisions(ctx context.Context, songID int, log *slog.Logger) ([]Revision, error) {
+	const op = "storage.postgres.ListRevisions()"
+
+	ctx, span := startSpan(ctx, "ListRevisions")
+	defer span.End()
+
+	var exists bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM song WHERE id = $1 AND deleted_at IS NULL);`, songID).Scan(&exists)
+	if err != nil {
+		log.Error("Error to get song", "error", err, "operation", op)
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrSongNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, revisionSelect+` WHERE r.id_song = $1 ORDER BY r.revision DESC;`, songID)
+	if err != nil {
+		log.Error("Error to get revisions", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			log.Error("Error to get revisions", "error", err, "operation", op)
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (s *Storage) GetRevision(ctx context.Context, songID, revision int, log *slog.Logger) (Revision, error) {
+	const op = "storage.postgres.GetRevision()"
+
+	ctx, span := startSpan(ctx, "GetRevision")
+	defer span.End()
+
+	r, err := scanRevision(s.db.QueryRowContext(ctx, revisionSelect+` WHERE r.id_song = $1 AND r.revision = $2;`, songID, revision))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Revision{}, ErrRevisionNotFound
+		}
+		log.Error("Error to get revision", "error", err, "operation", op)
+		return Revision{}, err
+	}
+
+	return r, nil
+}
+
+// RollbackInfo sets the info of a song back to a revision and records that as a new
+// revision. It returns ErrRevisionCurrent when the info already matches the revision. A
+// version other than 0 must match the one of the song.
+func (s *Storage) RollbackInfo(ctx context.Context, songID, revision int, editor string, ifVersion int, log *slog.Logger) (Revision, error) {
+	const op = "storage.postgres.RollbackInfo()"
+
+	ctx, span := startSpan(ctx, "RollbackInfo")
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Error to begin transaction", "error", err, "operation", op)
+		return Revision{}, err
+	}
+	defer tx.Rollback()
+
+	before, version, err := lockInfo(ctx, tx, songID, ifVersion)
+	if err != nil {
+		if !errors.Is(err, ErrSongNotFound) && !errors.Is(err, ErrVersionMismatch) {
+			log.Error("Error to get song info", "error", err, "operation", op)
+		}
+		return Revision{}, err
+	}
+
+	target, err := scanRevision(tx.QueryRowContext(ctx, revisionSelect+` WHERE r.id_song = $1 AND r.revision = $2;`, songID, revision))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Revision{}, ErrRevisionNotFound
+		}
+		log.Error("Error to get revision", "error", err, "operation", op)
+		return Revision{}, err
+	}
+
+	rev, err := writeInfo(ctx, tx, songID, version, before, target.InfoSong, editor, &revision)
+	if err != nil {
+		log.Error("Error to update", "error", err, "operation", op)
+		return Revision{}, err
+	}
+	if rev.Revision == 0 {
+		return Revision{}, ErrRevisionCurrent
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Error to commit transaction", "error", err, "operation", op)
+		return Revision{}, err
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"strings"
+)
+
+const (
+	SearchAll     = "all"
+	SearchLibrary = "library"
+	SearchCatalog = "catalog"
+)
+
+const (
+	LangEnglish = "english"
+	LangRussian = "russian"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// HighlightStart and HighlightStop wrap the matched words in snippets and lines. The rest
+// of the text is HTML-escaped, so the tags are the only markup in it.
+const (
+	HighlightStart = "<b>"
+	HighlightStop  = "</b>"
+)
+
+// markStart and markStop delimit the matched words in what ts_headline returns. They are
+// private use characters, dropped from the lyrics before the search, so the text can be
+// escaped first and the markers turned into the highlight tags after.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+var unmark = strings.NewReplacer(markStart, HighlightStart, markStop, HighlightStop)
+
+func highlightMarked(text string) string {
+	return unmark.Replace(html.EscapeString(text))
+}
+
+// Highlight HTML-escapes text and wraps the byte ranges in matches, as regexp returns
+// them, in HighlightStart and HighlightStop.
+func Highlight(text string, matches [][]int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString(HighlightStart)
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString(HighlightStop)
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
+
+type SearchQuery struct {
+	Text  string
+	Lang  string
+	Scope string
+	Limit int
+}
+
+// SearchResult is a song from the user library or the global catalog matching a search.
+type SearchResult struct {
+	Source  string      `json:"source"`
+	ID      int         `json:"id"`
+	Song    Song        `json:"song"`
+	Rank    float64     `json:"rank"`
+	Snippet string      `json:"snippet"`
+	Lines   []LineMatch `json:"lines"`
+}
+
+// LineMatch is a lyric line containing a search term. Number counts non-blank lines from 1,
+// the same way TextSong numbers them.
+type LineMatch struct {
+	Number int    `json:"line"`
+	Text   string `json:"text"`
+}
+
+// searchColumns maps a language to its text search configuration and precomputed column.
+var searchColumns = map[string]string{
+	LangEnglish: "search_en",
+	LangRussian: "search_ru",
+}
+
+func (q *SearchQuery) Normalize() error {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" {
+		return invalidQuery("q", "search text must not be empty")
+	}
+
+	switch strings.ToLower(q.Lang) {
+	case "", "en", LangEnglish:
+		q.Lang = LangEnglish
+	case "ru", LangRussian:
+		q.Lang = LangRussian
+	default:
+		return invalidQuery("lang", fmt.Sprintf("unsupported language %q, use english or russian", q.Lang))
+	}
+
+	switch q.Scope {
+	case "":
+		q.Scope = SearchAll
+	case SearchAll, SearchLibrary, SearchCatalog:
+	default:
+		return invalidQuery("scope", "scope must be all, library or catalog")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+
+	return nil
+}
+
+// searchText is the lyrics of a song without the characters used as highlight markers.
+const searchText = `translate(COALESCE(%[5]s, ''), '` + markStart + markStop + `', '')`
+
+// searchSelect is the per-table part of the search query. The placeholders are the source
+// name, the id, group, song and text expressions, the vector column, the FROM clause and the
+// text search configuration; the configuration and column come from searchColumns, never
+// from user input.
+const searchSelect = `
+	SELECT '%[1]s', %[2]s, %[3]s, %[4]s,
+		ts_rank_cd(%[6]s, q) AS rank,
+		ts_headline('%[8]s', ` + searchText + `, q,
+			'StartSel=` + markStart + `, StopSel=` + markStop + `, MaxFragments=2, MaxWords=20, MinWords=5'),
+		(SELECT json_agg(json_build_object('line', n, 'text',
+				ts_headline('%[8]s', line, q, 'HighlightAll=true, StartSel=` + markStart + `, StopSel=` + markStop + `')) ORDER BY n)
+			FROM (SELECT row_number() OVER (ORDER BY o) AS n, line
+				FROM regexp_split_to_table(` + searchText + `, E'\n') WITH ORDINALITY AS t(raw, o),
+					LATERAL (SELECT btrim(raw, E' \t\r') AS line) trimmed
+				WHERE line <> '') numbered
+			WHERE to_tsvector('%[8]s', line) @@ q)
+	FROM %[7]s, websearch_to_tsquery('%[8]s', $1) q
+	WHERE %[6]s @@ q`
+
+func (s *Storage) Search(ctx context.Context, q SearchQuery, log *slog.Logger) ([]SearchResult, error) {
+	const op = "storage.postgres.Search()"
+
+	ctx, span := startSpan(ctx, "Search")
+	defer span.End()
+
+	column := searchColumns[q.Lang]
+
+	var parts []string
+	if q.Scope != SearchCatalog {
+		parts = append(parts, fmt.Sprintf(searchSelect, SearchLibrary,
+			"s.id", "a.name", "s.song", "i.text", "i."+column,
+			"song s JOIN artist a ON a.id = s.id_artist JOIN infosong i ON s.id = i.id_song AND s.deleted_at IS NULL", q.Lang))
+	}
+	if q.Scope != SearchLibrary {
+		parts = append(parts, fmt.Sprintf(searchSelect, SearchCatalog,
+			"l.id", "a.name", "l.song", "l.text", "l."+column,
+			"library l JOIN artist a ON a.id = l.id_artist", q.Lang))
+	}
+
+	query := strings.Join(parts, "\n\tUNION ALL") + "\n\tORDER BY rank DESC LIMIT $2;"
+
+	rows, err := s.db.QueryContext(ctx, query, q.Text, q.Limit)
+	if err != nil {
+		log.Error("Error to search songs", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+
+	for rows.Next() {
+		var (
+			res   SearchResult
+			lines []byte
+		)
+		err = rows.Scan(&res.Source, &res.ID, &res.Song.Group, &res.Song.Name, &res.Rank, &res.Snippet, &lines)
+		if err != nil {
+			log.Error("Error to search songs", "error", err, "operation", op)
+			return nil, err
+		}
+
+		res.Snippet = highlightMarked(res.Snippet)
+		res.Lines = []LineMatch{}
+		if lines != nil {
+			if err = json.Unmarshal(lines, &res.Lines); err != nil {
+				log.Error("Error to decode matching lines", "error", err, "operation", op)
+				return nil, err
+			}
+		}
+		for i := range res.Lines {
+			res.Lines[i].Text = highlightMarked(res.Lines[i].Text)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
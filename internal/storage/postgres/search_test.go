@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	pattern := regexp.MustCompile(`(?i)love`)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"All you need is love", "All you need is <b>love</b>"},
+		{"<script>love</script>", "&lt;script&gt;<b>love</b>&lt;/script&gt;"},
+		{"Love & LOVE", "<b>Love</b> &amp; <b>LOVE</b>"},
+		{"no match", "no match"},
+	}
+
+	for _, tt := range tests {
+		if got := Highlight(tt.text, pattern.FindAllStringIndex(tt.text, -1)); got != tt.want {
+			t.Errorf("Highlight(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightMarked(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"is " + markStart + "love" + markStop, "is <b>love</b>"},
+		{`<img src=x onerror="` + markStart + "love" + markStop + `">`, "&lt;img src=x onerror=&#34;<b>love</b>&#34;&gt;"},
+	}
+
+	for _, tt := range tests {
+		if got := highlightMarked(tt.text); got != tt.want {
+			t.Errorf("highlightMarked(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQueryNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		q         SearchQuery
+		want      SearchQuery
+		wantField string
+	}{
+		{
+			name: "defaults",
+			q:    SearchQuery{Text: "  love "},
+			want: SearchQuery{Text: "love", Lang: LangEnglish, Scope: SearchAll, Limit: DefaultSearchLimit},
+		},
+		{
+			name: "short language and capped limit",
+			q:    SearchQuery{Text: "любовь", Lang: "RU", Scope: SearchCatalog, Limit: MaxSearchLimit + 1},
+			want: SearchQuery{Text: "любовь", Lang: LangRussian, Scope: SearchCatalog, Limit: MaxSearchLimit},
+		},
+		{name: "blank text", q: SearchQuery{Text: " "}, wantField: "q"},
+		{name: "unknown language", q: SearchQuery{Text: "love", Lang: "german"}, wantField: "lang"},
+		{name: "unknown scope", q: SearchQuery{Text: "love", Scope: "trash"}, wantField: "scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.q
+			err := q.Normalize()
+			if tt.wantField != "" {
+				var invalidErr *InvalidError
+				if !errors.As(err, &invalidErr) || invalidErr.Field != tt.wantField {
+					t.Fatalf("Normalize() error = %v, want one about %s", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() error: %v", err)
+			}
+			if q != tt.want {
+				t.Errorf("Normalize() = %+v, want %+v", q, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Genre is a node of the genre tree; subgenres name their parent.
+type Genre struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parentId"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+const MaxTagLength = 50
+
+var (
+	ErrGenreNotFound = errors.New("genre not found")
+	ErrGenreExists   = errors.New("genre already exists")
+)
+
+// NormalizeTag lower-cases a tag and collapses its whitespace, so "Road  Trip" and
+// "road trip" are the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+func (s *Storage) CreateGenre(ctx context.Context, name string, parentID *int, log *slog.Logger) (Genre, error) {
+	const op = "storage.postgres.CreateGenre()"
+
+	ctx, span := startSpan(ctx, "CreateGenre")
+	defer span.End()
+
+	query := `INSERT INTO genre (name, id_parent) VALUES ($1, $2) RETURNING id`
+
+	g := Genre{Name: name, ParentID: parentID}
+
+	err := s.db.QueryRowContext(ctx, query, name, parentID).Scan(&g.ID)
+	if err != nil {
+		switch pqCode(err) {
+		case codeUniqueViolation:
+			return Genre{}, ErrGenreExists
+		case codeForeignKeyViolation:
+			return Genre{}, ErrGenreNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return Genre{}, err
+	}
+
+	return g, nil
+}
+
+func (s *Storage) ListGenres(ctx context.Context, log *slog.Logger) ([]Genre, error) {
+	const op = "storage.postgres.ListGenres()"
+
+	ctx, span := startSpan(ctx, "ListGenres")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, id_parent FROM genre ORDER BY id;`)
+	if err != nil {
+		log.Error("Error to get genres", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []Genre{}
+
+	for rows.Next() {
+		var g Genre
+		if err = rows.Scan(&g.ID, &g.Name, &g.ParentID); err != nil {
+			log.Error("Error to get genres", "error", err, "operation", op)
+			return nil, err
+		}
+		genres = append(genres, g)
+	}
+
+	return genres, rows.Err()
+}
+
+func (s *Storage) AddSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error {
+	const op = "storage.postgres.AddSongGenre()"
+
+	ctx, span := startSpan(ctx, "AddSongGenre")
+	defer span.End()
+
+	query := `INSERT INTO song_genre (id_song, id_genre)
+				SELECT $1, $2 WHERE EXISTS (SELECT 1 FROM song WHERE id = $1 AND deleted_at IS NULL)
+				ON CONFLICT DO NOTHING;`
+
+	res, err := s.db.ExecContext(ctx, query, songID, genreID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == codeForeignKeyViolation {
+			if pqErr.Constraint == "song_genre_id_song_fkey" {
+				return ErrSongNotFound
+			}
+			return ErrGenreNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if err = liveSong(ctx, s.db, songID); err != nil && !errors.Is(err, ErrSongNotFound) {
+			log.Error("Error to get song", "error", err, "operation", op)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error {
+	const op = "storage.postgres.RemoveSongGenre()"
+
+	ctx, span := startSpan(ctx, "RemoveSongGenre")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM song_genre WHERE id_song = $1 AND id_genre = $2;`, songID, genreID)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	return nil
+}
+
+// AddSongTags attaches normalized tags to a song; tags it already has are left alone.
+func (s *Storage) AddSongTags(ctx context.Context, songID int, tags []string, log *slog.Logger) error {
+	const op = "storage.postgres.AddSongTags()"
+
+	ctx, span := startSpan(ctx, "AddSongTags")
+	defer span.End()
+
+	query := `INSERT INTO song_tag (id_song, tag)
+				SELECT $1, t.tag FROM unnest($2::varchar[]) AS t(tag)
+				WHERE EXISTS (SELECT 1 FROM song WHERE id = $1 AND deleted_at IS NULL)
+				ON CONFLICT DO NOTHING;`
+
+	res, err := s.db.ExecContext(ctx, query, songID, pq.Array(tags))
+	if err != nil {
+		if pqCode(err) == codeForeignKeyViolation {
+			return ErrSongNotFound
+		}
+		log.Error("Error to insert", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if err = liveSong(ctx, s.db, songID); err != nil && !errors.Is(err, ErrSongNotFound) {
+			log.Error("Error to get song", "error", err, "operation", op)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveSongTag(ctx context.Context, songID int, tag string, log *slog.Logger) error {
+	const op = "storage.postgres.RemoveSongTag()"
+
+	ctx, span := startSpan(ctx, "RemoveSongTag")
+	defer span.End()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM song_tag WHERE id_song = $1 AND tag = $2;`, songID, tag)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	return nil
+}
+
+// TagCounts returns every tag with the number of library songs that have it, most used first.
+func (s *Storage) TagCounts(ctx context.Context, log *slog.Logger) ([]TagCount, error) {
+	const op = "storage.postgres.TagCounts()"
+
+	ctx, span := startSpan(ctx, "TagCounts")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT st.tag, count(*) FROM song_tag st JOIN song s ON s.id = st.id_song AND s.deleted_at IS NULL
+		GROUP BY st.tag ORDER BY count(*) DESC, st.tag;`)
+	if err != nil {
+		log.Error("Error to count tags", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+
+	for rows.Next() {
+		var c TagCount
+		if err = rows.Scan(&c.Tag, &c.Count); err != nil {
+			log.Error("Error to count tags", "error", err, "operation", op)
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
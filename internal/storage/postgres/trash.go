@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// TrashedSong is a deleted song waiting in the trash to be restored or purged.
+type TrashedSong struct {
+	Songs     Songs     `json:"songs"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// ListTrash returns the trashed songs, most recently deleted first.
+func (s *Storage) ListTrash(ctx context.Context, log *slog.Logger) ([]TrashedSong, error) {
+	const op = "storage.postgres.ListTrash()"
+
+	ctx, span := startSpan(ctx, "ListTrash")
+	defer span.End()
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, ''), s.deleted_at
+				FROM song s
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE s.deleted_at IS NOT NULL
+				ORDER BY s.deleted_at DESC, s.id;`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Error("Error to get trash", "error", err, "operation", op)
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []TrashedSong{}
+
+	for rows.Next() {
+		var t TrashedSong
+		err = rows.Scan(&t.Songs.ID,
+			&t.Songs.Song.Group,
+			&t.Songs.Song.Name,
+			&t.Songs.InfoSong.Text,
+			&t.Songs.InfoSong.ReleaseDate,
+			&t.Songs.InfoSong.Link,
+			&t.DeletedAt)
+		if err != nil {
+			log.Error("Error to get trash", "error", err, "operation", op)
+			return nil, err
+		}
+		trash = append(trash, t)
+	}
+
+	return trash, rows.Err()
+}
+
+// RestoreSong takes a song out of the trash, back into the library with everything it had.
+func (s *Storage) RestoreSong(ctx context.Context, id int, log *slog.Logger) (Songs, error) {
+	const op = "storage.postgres.RestoreSong()"
+
+	ctx, span := startSpan(ctx, "RestoreSong")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE song SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;`, id)
+	if err != nil {
+		log.Error("Error to update", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return Songs{}, ErrSongNotFound
+	}
+
+	query := `SELECT s.id, a.name, s.song, COALESCE(i.text, ''), i.releasedate, COALESCE(i.link, '')
+				FROM song s
+				JOIN artist a ON a.id = s.id_artist
+				JOIN infosong i ON i.id_song = s.id
+				WHERE s.id = $1;`
+
+	var restored Songs
+
+	err = s.db.QueryRowContext(ctx, query, id).Scan(&restored.ID,
+		&restored.Song.Group,
+		&restored.Song.Name,
+		&restored.InfoSong.Text,
+		&restored.InfoSong.ReleaseDate,
+		&restored.InfoSong.Link)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Songs{}, ErrSongNotFound
+		}
+		log.Error("Error to get song", "error", err, "operation", op)
+		return Songs{}, err
+	}
+
+	return restored, nil
+}
+
+// PurgeSong deletes a trashed song for good, together with its info and everything
+// referencing it.
+func (s *Storage) PurgeSong(ctx context.Context, id int, log *slog.Logger) error {
+	const op = "storage.postgres.PurgeSong()"
+
+	ctx, span := startSpan(ctx, "PurgeSong")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM song WHERE id = $1 AND deleted_at IS NOT NULL;`, id)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSongNotFound
+	}
+
+	return nil
+}
+
+// PurgeTrash deletes for good the songs trashed before the time and returns how many.
+func (s *Storage) PurgeTrash(ctx context.Context, before time.Time, log *slog.Logger) (int, error) {
+	const op = "storage.postgres.PurgeTrash()"
+
+	ctx, span := startSpan(ctx, "PurgeTrash")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM song WHERE deleted_at < $1;`, before)
+	if err != nil {
+		log.Error("Error to delete", "error", err, "operation", op)
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"songLibrary/internal/storage/memory"
+	"songLibrary/internal/storage/postgres"
+	"time"
+)
+
+const (
+	TypePostgres = "postgres"
+	TypeMemory   = "memory"
+)
+
+// SongStore is the set of operations the API handlers need from a storage backend. Songs
+// carry a version that changes with them; a change made with a version other than 0 fails
+// with postgres.ErrVersionMismatch unless the song is still at that version.
+type SongStore interface {
+	AddSong(ctx context.Context, song postgres.Song, info postgres.InfoSong, log *slog.Logger) (postgres.Songs, error)
+	FindSong(ctx context.Context, group, song string, log *slog.Logger) (postgres.Songs, error)
+	GetSong(ctx context.Context, id int, log *slog.Logger) (postgres.Songs, int, error)
+	ChangeInfo(ctx context.Context, id int, info postgres.InfoSong, editor string, ifVersion int, log *slog.Logger) (int, error)
+	PatchSong(ctx context.Context, id int, patch postgres.SongPatch, editor string, ifVersion int, log *slog.Logger) (postgres.Songs, int, error)
+	DeleteSong(ctx context.Context, id, ifVersion int, log *slog.Logger) (sql.Result, error)
+	GetText(ctx context.Context, id int, log *slog.Logger) (string, error)
+	GetLibrary(ctx context.Context, q postgres.LibraryQuery, log *slog.Logger) (postgres.LibraryPage, error)
+	GetInfo(ctx context.Context, group, song string, log *slog.Logger) (postgres.InfoSong, error)
+	GetLibraryMain(ctx context.Context, q postgres.LibraryQuery, log *slog.Logger) (postgres.LibraryPage, error)
+}
+
+// SearchStore runs full-text search over lyrics, groups and song names.
+type SearchStore interface {
+	Search(ctx context.Context, q postgres.SearchQuery, log *slog.Logger) ([]postgres.SearchResult, error)
+}
+
+// KeyStore keeps API keys. Keys are looked up by the SHA-256 hash of their secret.
+type KeyStore interface {
+	CreateKey(ctx context.Context, name, role, prefix, hash string, log *slog.Logger) (postgres.APIKey, error)
+	GetKeyByHash(ctx context.Context, hash string, log *slog.Logger) (postgres.APIKey, error)
+	ListKeys(ctx context.Context, log *slog.Logger) ([]postgres.APIKey, error)
+	DeleteKey(ctx context.Context, id int, log *slog.Logger) error
+}
+
+// PlaylistStore keeps named playlists of library songs in a user-defined order.
+type PlaylistStore interface {
+	CreatePlaylist(ctx context.Context, name string, log *slog.Logger) (postgres.Playlist, error)
+	ListPlaylists(ctx context.Context, log *slog.Logger) ([]postgres.Playlist, error)
+	GetPlaylist(ctx context.Context, id int, log *slog.Logger) (postgres.PlaylistDetails, error)
+	RenamePlaylist(ctx context.Context, id int, name string, log *slog.Logger) (postgres.Playlist, error)
+	DeletePlaylist(ctx context.Context, id int, log *slog.Logger) error
+	AddToPlaylist(ctx context.Context, id, songID, position int, log *slog.Logger) error
+	RemoveFromPlaylist(ctx context.Context, id, songID int, log *slog.Logger) error
+	MovePlaylistSong(ctx context.Context, id, songID, position int, log *slog.Logger) error
+}
+
+// ArtistStore keeps the artists songs reference. Songs of an artist are listed through
+// SongStore.GetLibrary with LibraryQuery.ArtistID.
+type ArtistStore interface {
+	ListArtists(ctx context.Context, log *slog.Logger) ([]postgres.Artist, error)
+	GetArtist(ctx context.Context, id int, log *slog.Logger) (postgres.Artist, error)
+	RenameArtist(ctx context.Context, id int, name, sortName string, log *slog.Logger) (postgres.Artist, error)
+}
+
+// AlbumStore keeps albums and their track listings of user library songs.
+type AlbumStore interface {
+	CreateAlbum(ctx context.Context, album postgres.NewAlbum, log *slog.Logger) (postgres.AlbumDetails, error)
+	ListAlbums(ctx context.Context, q postgres.AlbumQuery, log *slog.Logger) (postgres.AlbumPage, error)
+	GetAlbum(ctx context.Context, id int, log *slog.Logger) (postgres.AlbumDetails, error)
+	SetAlbumTracks(ctx context.Context, id int, songIDs []int, log *slog.Logger) (postgres.AlbumDetails, error)
+	DeleteAlbum(ctx context.Context, id int, log *slog.Logger) error
+}
+
+// TaxonomyStore keeps the genre tree and the genres and free-form tags of user library songs.
+// Songs are listed by tag or genre through SongStore with LibraryQuery.Tag and Genre.
+type TaxonomyStore interface {
+	CreateGenre(ctx context.Context, name string, parentID *int, log *slog.Logger) (postgres.Genre, error)
+	ListGenres(ctx context.Context, log *slog.Logger) ([]postgres.Genre, error)
+	AddSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error
+	RemoveSongGenre(ctx context.Context, songID, genreID int, log *slog.Logger) error
+	AddSongTags(ctx context.Context, songID int, tags []string, log *slog.Logger) error
+	RemoveSongTag(ctx context.Context, songID int, tag string, log *slog.Logger) error
+	TagCounts(ctx context.Context, log *slog.Logger) ([]postgres.TagCount, error)
+}
+
+// RatingStore keeps the favorites and star ratings of each user. Rating aggregates of every
+// song are listed through SongStore with the library.
+type RatingStore interface {
+	AddFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error
+	RemoveFavorite(ctx context.Context, userID string, songID int, log *slog.Logger) error
+	ListFavorites(ctx context.Context, userID string, log *slog.Logger) ([]postgres.Favorite, error)
+	RateSong(ctx context.Context, userID string, songID, stars int, log *slog.Logger) error
+	RemoveRating(ctx context.Context, userID string, songID int, log *slog.Logger) error
+	ListRatings(ctx context.Context, userID string, log *slog.Logger) ([]postgres.Rating, error)
+	TopRated(ctx context.Context, q postgres.TopRatedQuery, log *slog.Logger) ([]postgres.RatedSong, error)
+}
+
+// PlayStore keeps the listening history. Plays are written in batches by plays.Recorder.
+type PlayStore interface {
+	AddPlays(ctx context.Context, plays []postgres.Play, log *slog.Logger) (int, error)
+	RecentPlays(ctx context.Context, limit int, log *slog.Logger) ([]postgres.RecentPlay, error)
+	MostPlayedSongs(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.PlayedSong, error)
+	MostPlayedArtists(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.PlayedArtist, error)
+	DailyPlays(ctx context.Context, q postgres.PlayQuery, log *slog.Logger) ([]postgres.DayPlays, error)
+}
+
+// RevisionStore keeps the revisions SongStore.ChangeInfo records of the info of a song.
+type RevisionStore interface {
+	ListRevisions(ctx context.Context, songID int, log *slog.Logger) ([]postgres.Revision, error)
+	GetRevision(ctx context.Context, songID, revision int, log *slog.Logger) (postgres.Revision, error)
+	RollbackInfo(ctx context.Context, songID, revision int, editor string, ifVersion int, log *slog.Logger) (postgres.Revision, error)
+}
+
+// TrashStore keeps the songs SongStore.DeleteSong moved to the trash until they are restored
+// or purged for good.
+type TrashStore interface {
+	ListTrash(ctx context.Context, log *slog.Logger) ([]postgres.TrashedSong, error)
+	RestoreSong(ctx context.Context, id int, log *slog.Logger) (postgres.Songs, error)
+	PurgeSong(ctx context.Context, id int, log *slog.Logger) error
+	PurgeTrash(ctx context.Context, before time.Time, log *slog.Logger) (int, error)
+}
+
+// ExportStore streams the whole user library.
+type ExportStore interface {
+	ExportLibrary(ctx context.Context, lyrics bool, fn func(song postgres.Songs) error, log *slog.Logger) error
+}
+
+// CountStore counts the songs of the library, the trash and the global catalog.
+type CountStore interface {
+	CountSongs(ctx context.Context, log *slog.Logger) (postgres.SongCounts, error)
+}
+
+// Store is everything a storage backend provides to the API.
+type Store interface {
+	SongStore
+	SearchStore
+	KeyStore
+	PlaylistStore
+	ArtistStore
+	AlbumStore
+	TaxonomyStore
+	RatingStore
+	PlayStore
+	ExportStore
+	TrashStore
+	RevisionStore
+	CountStore
+
+	// Close releases the resources of the backend, such as the database pool.
+	Close() error
+}
+
+var (
+	_ Store = (*postgres.Storage)(nil)
+	_ Store = (*memory.Storage)(nil)
+)
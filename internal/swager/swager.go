@@ -4,14 +4,20 @@ import (
 	"github.com/go-chi/chi"
 	"log/slog"
 	"songLibrary/internal/api"
-	"songLibrary/internal/storage/postgres"
+	"songLibrary/internal/auth"
+	"songLibrary/internal/catalog"
+	"songLibrary/internal/storage"
 )
 
-func InitRoutes(r *chi.Mux, log *slog.Logger, storage *postgres.Storage) {
-	r.HandleFunc("/songs/add", api.AddSongHandler(log, storage))
-	r.HandleFunc("/songs/change", api.ChangeInfoSongHandler(log, storage))
-	r.HandleFunc("/songs/delete", api.DeleteSongHandler(log, storage))
-	r.HandleFunc("/songs/text", api.TextSongHandler(log, storage))
-	r.HandleFunc("/library", api.LibraryHandler(log, storage))
-	r.HandleFunc("/info", api.InfoHandler(log, storage))
+func InitRoutes(r *chi.Mux, log *slog.Logger, storage storage.SongStore, catalogClient *catalog.Client, authenticator *auth.Authenticator) {
+	reader := r.With(authenticator.Require(auth.RoleReader))
+	editor := r.With(authenticator.Require(auth.RoleEditor))
+	admin := r.With(authenticator.Require(auth.RoleAdmin))
+
+	editor.HandleFunc("/songs/add", api.AddSongHandler(log, storage, catalogClient))
+	editor.HandleFunc("/songs/change", api.ChangeInfoSongHandler(log, storage))
+	admin.HandleFunc("/songs/delete", api.DeleteSongHandler(log, storage))
+	reader.HandleFunc("/songs/text", api.TextSongHandler(log, storage))
+	reader.HandleFunc("/library", api.LibraryHandler(log, storage))
+	reader.HandleFunc("/info", api.InfoHandler(log, storage))
 }
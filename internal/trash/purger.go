@@ -0,0 +1,56 @@
+package trash
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"songLibrary/internal/config"
+	"songLibrary/internal/storage"
+	"time"
+)
+
+// Purger deletes for good the songs that have been in the trash longer than the retention.
+type Purger struct {
+	store     storage.TrashStore
+	retention time.Duration
+	interval  time.Duration
+	log       *slog.Logger
+}
+
+func NewPurger(store storage.TrashStore, cfg config.Trash, log *slog.Logger) *Purger {
+	return &Purger{
+		store:     store,
+		retention: cfg.Retention,
+		interval:  cmp.Or(cfg.PurgeInterval, time.Hour),
+		log:       log,
+	}
+}
+
+// Run purges expired songs at start and then every purge interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	const op = "internal.trash.purge()"
+
+	n, err := p.store.PurgeTrash(ctx, time.Now().Add(-p.retention), p.log)
+	if err != nil {
+		p.log.Error("Error purging trash", "error", err, "operation", op)
+		return
+	}
+	if n > 0 {
+		p.log.Info("trashed songs purged", "songs", n, "retention", p.retention)
+	}
+}